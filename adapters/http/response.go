@@ -24,7 +24,9 @@ func Response(c *fiber.Ctx, response entities.Response, options ...map[string]in
 	}
 
 	if response.Status == "OK" {
-		logger.Infow("response success", fields...)
+		if !logging.QuietSuccess(ctx) {
+			logger.Infow("response success", fields...)
+		}
 	} else {
 		fields = append(fields, "error_message", response.ErrorMessage)
 		logger.Errorw("response error", fields...)
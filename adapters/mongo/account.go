@@ -0,0 +1,82 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpdateProfile sets the name and the email in one update, so neither is
+// saved when the other fails; an empty value is left as it is. A new address
+// is marked unverified.
+func (rp *MongoRepository) UpdateProfile(userId string, name string, email string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	set := bson.M{}
+	update := bson.M{"$set": set}
+	if name != "" {
+		set["name"] = name
+	}
+	if email != "" {
+		set["email"] = email
+		set["emailVerified"] = false
+		update["$unset"] = bson.M{"emailVerifiedAt": ""}
+	}
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) ScheduleDeletion(userId string, at time.Time, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"deletionScheduledAt": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) CancelDeletion(userId string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":                 oid,
+		"deletionScheduledAt": bson.M{"$exists": true},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"deletionScheduledAt": ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("account deletion is not scheduled")
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (rp *MongoRepository) CreateAPIKey(key entities.APIKey, ctx context.Context) error {
+	coll := rp.db().Collection("api_key")
+	_, err := coll.InsertOne(ctx, key)
+	return err
+}
+
+func (rp *MongoRepository) ListAPIKeys(ctx context.Context) ([]entities.APIKey, error) {
+	coll := rp.db().Collection("api_key")
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []entities.APIKey{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) GetAPIKey(keyId string, ctx context.Context) (result entities.APIKey, err error) {
+	coll := rp.db().Collection("api_key")
+	oid, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return result, fmt.Errorf("invalid API key ID format: %w", err)
+	}
+
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) GetAPIKeyByPrefix(prefix string, ctx context.Context) (result entities.APIKey, err error) {
+	coll := rp.db().Collection("api_key")
+	if err := coll.FindOne(ctx, bson.M{"prefix": prefix}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) UpdateAPIKey(keyId string, data entities.UpdateAPIKeyRequest, ctx context.Context) error {
+	coll := rp.db().Collection("api_key")
+	oid, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID format: %w", err)
+	}
+
+	update := bson.M{}
+	if data.Name != "" {
+		update["name"] = data.Name
+	}
+	if len(data.Scopes) > 0 {
+		update["scopes"] = data.Scopes
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": oid, "revokedAt": nil}, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) RevokeAPIKey(keyId string, ctx context.Context) error {
+	coll := rp.db().Collection("api_key")
+	oid, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID format: %w", err)
+	}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": oid, "revokedAt": nil}, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// TouchAPIKey updates lastUsedAt at most once per interval.
+func (rp *MongoRepository) TouchAPIKey(keyId string, interval time.Duration, ctx context.Context) error {
+	coll := rp.db().Collection("api_key")
+	oid, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID format: %w", err)
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": oid,
+		"$or": []bson.M{
+			{"lastUsedAt": nil},
+			{"lastUsedAt": bson.M{"$lt": now.Add(-interval)}},
+		},
+	}
+	_, err = coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastUsedAt": now}})
+	return err
+}
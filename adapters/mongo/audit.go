@@ -0,0 +1,12 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+func (rp *MongoRepository) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	coll := rp.db().Collection("audit_log")
+	_, err := coll.InsertOne(ctx, event)
+	return err
+}
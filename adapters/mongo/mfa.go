@@ -0,0 +1,161 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (rp *MongoRepository) SetPendingMFASecret(userId string, secret string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":         oid,
+		"mfa.enabled": bson.M{"$ne": true},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"mfa.pendingSecret": secret}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("MFA is already enabled")
+	}
+
+	return nil
+}
+
+// EnableMFA promotes the pending secret; the filter makes sure the secret the
+// code was checked against is still the pending one.
+func (rp *MongoRepository) EnableMFA(userId string, secret string, recoveryCodes []string, step int64, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":               oid,
+		"mfa.pendingSecret": secret,
+	}
+	update := bson.M{"$set": bson.M{
+		"mfa.enabled":       true,
+		"mfa.secret":        secret,
+		"mfa.recoveryCodes": recoveryCodes,
+		"mfa.lastUsedStep":  step,
+		"mfa.enabledAt":     time.Now(),
+	}, "$unset": bson.M{"mfa.pendingSecret": ""}}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("MFA enrolment was not started or has changed")
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code so it cannot be replayed.
+func (rp *MongoRepository) UseTOTPStep(userId string, step int64, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":              oid,
+		"mfa.enabled":      true,
+		"mfa.lastUsedStep": bson.M{"$lt": step},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"mfa.lastUsedStep": step}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("code has already been used")
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) ConsumeRecoveryCode(userId string, codeHash string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":               oid,
+		"mfa.enabled":       true,
+		"mfa.recoveryCodes": codeHash,
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"mfa.recoveryCodes": codeHash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("invalid recovery code")
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) CreateMFAChallenge(challenge entities.MFAChallenge, ctx context.Context) error {
+	coll := rp.db().Collection("mfa_challenge")
+	_, err := coll.InsertOne(ctx, challenge)
+	return err
+}
+
+// UseMFAChallengeAttempt counts an attempt before the code is checked, so
+// parallel guesses cannot go past maxAttempts either.
+func (rp *MongoRepository) UseMFAChallengeAttempt(challengeId string, userId string, maxAttempts int, ctx context.Context) error {
+	coll := rp.db().Collection("mfa_challenge")
+	filter := bson.M{
+		"_id":       challengeId,
+		"userId":    userId,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"attempts":  bson.M{"$lt": maxAttempts},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("MFA challenge is invalid, expired, already used or has too many attempts")
+	}
+
+	return nil
+}
+
+// ConsumeMFAChallenge marks the challenge as used in a single atomic update,
+// so it can never be exchanged for a second access token.
+func (rp *MongoRepository) ConsumeMFAChallenge(challengeId string, ctx context.Context) error {
+	coll := rp.db().Collection("mfa_challenge")
+	now := time.Now()
+	filter := bson.M{
+		"_id":       challengeId,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("MFA challenge is invalid, expired or already used")
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (rp *MongoRepository) CreateOAuthClient(client entities.OAuthClient, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_client")
+	_, err := coll.InsertOne(ctx, client)
+	return err
+}
+
+func (rp *MongoRepository) ListOAuthClients(ctx context.Context) ([]entities.OAuthClient, error) {
+	coll := rp.db().Collection("oauth_client")
+	cursor, err := coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []entities.OAuthClient{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) GetOAuthClient(clientId string, ctx context.Context) (result entities.OAuthClient, err error) {
+	coll := rp.db().Collection("oauth_client")
+	if err := coll.FindOne(ctx, bson.M{"clientId": clientId}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// RevokeOAuthClient disables the client and every refresh token issued to it.
+func (rp *MongoRepository) RevokeOAuthClient(clientId string, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_client")
+	now := time.Now()
+	result, err := coll.UpdateOne(ctx, bson.M{"clientId": clientId, "revokedAt": nil}, bson.M{"$set": bson.M{"revokedAt": now}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = rp.db().Collection("oauth_refresh_token").UpdateMany(ctx, bson.M{"clientId": clientId, "revokedAt": nil}, bson.M{"$set": bson.M{"revokedAt": now}})
+	return err
+}
+
+// SaveOAuthConsent adds scopes to the user's consent for the client, creating
+// it on first use.
+func (rp *MongoRepository) SaveOAuthConsent(consent entities.OAuthConsent, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_consent")
+	filter := bson.M{"userId": consent.UserID, "clientId": consent.ClientID}
+	update := bson.M{
+		"$addToSet":    bson.M{"scopes": bson.M{"$each": consent.Scopes}},
+		"$set":         bson.M{"updatedAt": consent.UpdatedAt},
+		"$setOnInsert": bson.M{"createdAt": consent.CreatedAt},
+	}
+	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (rp *MongoRepository) GetOAuthConsent(userId string, clientId string, ctx context.Context) (result entities.OAuthConsent, err error) {
+	coll := rp.db().Collection("oauth_consent")
+	if err := coll.FindOne(ctx, bson.M{"userId": userId, "clientId": clientId}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) ListOAuthConsents(userId string, ctx context.Context) ([]entities.OAuthConsent, error) {
+	coll := rp.db().Collection("oauth_consent")
+	cursor, err := coll.Find(ctx, bson.M{"userId": userId}, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []entities.OAuthConsent{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RevokeOAuthConsent removes the consent and the refresh tokens the client
+// holds for the user, and records when it happened so access tokens issued
+// before are rejected too.
+func (rp *MongoRepository) RevokeOAuthConsent(userId string, clientId string, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_consent")
+	result, err := coll.DeleteOne(ctx, bson.M{"userId": userId, "clientId": clientId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	now := time.Now()
+	filter := bson.M{"userId": userId, "clientId": clientId}
+	_, err = rp.db().Collection("oauth_consent_revocation").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revokedAt": now}}, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	filter["revokedAt"] = nil
+	_, err = rp.db().Collection("oauth_refresh_token").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": now}})
+	return err
+}
+
+// OAuthTokensRevokedAt is the latest of the client's revocation and, for a
+// user token, the user's last consent revocation for the client. Zero when
+// neither happened.
+func (rp *MongoRepository) OAuthTokensRevokedAt(clientId string, userId string, ctx context.Context) (time.Time, error) {
+	var client entities.OAuthClient
+	opts := options.FindOne().SetProjection(bson.M{"revokedAt": 1})
+	if err := rp.db().Collection("oauth_client").FindOne(ctx, bson.M{"clientId": clientId}, opts).Decode(&client); err != nil {
+		return time.Time{}, err
+	}
+	var revokedAt time.Time
+	if client.RevokedAt != nil {
+		revokedAt = *client.RevokedAt
+	}
+	if userId == "" {
+		return revokedAt, nil
+	}
+
+	var consent struct {
+		RevokedAt time.Time `bson:"revokedAt"`
+	}
+	err := rp.db().Collection("oauth_consent_revocation").FindOne(ctx, bson.M{"userId": userId, "clientId": clientId}).Decode(&consent)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, err
+	}
+	if consent.RevokedAt.After(revokedAt) {
+		revokedAt = consent.RevokedAt
+	}
+	return revokedAt, nil
+}
+
+func (rp *MongoRepository) CreateOAuthCode(code entities.OAuthCode, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_code")
+	_, err := coll.InsertOne(ctx, code)
+	return err
+}
+
+// ConsumeOAuthCode deletes the code so it can only be exchanged once.
+func (rp *MongoRepository) ConsumeOAuthCode(codeHash string, ctx context.Context) (result entities.OAuthCode, err error) {
+	coll := rp.db().Collection("oauth_code")
+	filter := bson.M{
+		"_id":       codeHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	if err := coll.FindOneAndDelete(ctx, filter).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return result, fmt.Errorf("authorization code is invalid or expired")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) CreateOAuthRefreshToken(token entities.OAuthRefreshToken, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_refresh_token")
+	_, err := coll.InsertOne(ctx, token)
+	return err
+}
+
+func (rp *MongoRepository) GetOAuthRefreshToken(tokenHash string, ctx context.Context) (result entities.OAuthRefreshToken, err error) {
+	coll := rp.db().Collection("oauth_refresh_token")
+	if err := coll.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ConsumeOAuthRefreshToken marks the token as used and returns it. A token
+// that was already used is returned with RevokedAt set, so the caller can
+// detect reuse.
+func (rp *MongoRepository) ConsumeOAuthRefreshToken(tokenHash string, ctx context.Context) (result entities.OAuthRefreshToken, err error) {
+	coll := rp.db().Collection("oauth_refresh_token")
+	filter := bson.M{"_id": tokenHash, "revokedAt": nil}
+	err = coll.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rp.GetOAuthRefreshToken(tokenHash, ctx)
+	}
+
+	return result, err
+}
+
+func (rp *MongoRepository) RevokeOAuthRefreshFamily(family string, ctx context.Context) error {
+	coll := rp.db().Collection("oauth_refresh_token")
+	_, err := coll.UpdateMany(ctx, bson.M{"family": family, "revokedAt": nil}, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return err
+}
+
+func (rp *MongoRepository) RevokeAccessToken(tokenId string, expiresAt time.Time, ctx context.Context) error {
+	coll := rp.db().Collection("revoked_access_token")
+	update := bson.M{"$setOnInsert": bson.M{"expiresAt": expiresAt}}
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": tokenId}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (rp *MongoRepository) IsAccessTokenRevoked(tokenId string, ctx context.Context) (bool, error) {
+	coll := rp.db().Collection("revoked_access_token")
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": tokenId}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,71 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (rp *MongoRepository) CreateOIDCLogin(login entities.OIDCLogin, ctx context.Context) error {
+	coll := rp.db().Collection("oidc_login")
+	_, err := coll.InsertOne(ctx, login)
+	return err
+}
+
+// ConsumeOIDCLogin deletes the login state so a callback can only be used once.
+func (rp *MongoRepository) ConsumeOIDCLogin(state string, ctx context.Context) (result entities.OIDCLogin, err error) {
+	coll := rp.db().Collection("oidc_login")
+	filter := bson.M{
+		"_id":       state,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	if err := coll.FindOneAndDelete(ctx, filter).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return result, fmt.Errorf("login state is invalid or expired")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) FindUserByIdentity(provider string, subject string, ctx context.Context) (result entities.User, err error) {
+	coll := rp.db().Collection("user")
+	filter := bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}
+	if err := coll.FindOne(ctx, filter).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) LinkIdentity(userId string, identity entities.ExternalIdentity, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":                 oid,
+		"identities.provider": bson.M{"$ne": identity.Provider},
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"identities": identity}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("account is already linked to %s", identity.Provider)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (rp *MongoRepository) CreatePasswordReset(reset entities.PasswordReset, ctx context.Context) error {
+	coll := rp.db().Collection("password_reset")
+	_, err := coll.InsertOne(ctx, reset)
+	return err
+}
+
+func (rp *MongoRepository) ConsumePasswordReset(tokenHash string, ctx context.Context) (result entities.PasswordReset, err error) {
+	coll := rp.db().Collection("password_reset")
+	now := time.Now()
+	filter := bson.M{
+		"_id":       tokenHash,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"usedAt": now}}
+
+	err = coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return result, fmt.Errorf("reset token is invalid, expired or already used")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) InvalidatePasswordResets(userId string, ctx context.Context) error {
+	coll := rp.db().Collection("password_reset")
+	filter := bson.M{
+		"userId": userId,
+		"usedAt": nil,
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"usedAt": time.Now()}})
+	return err
+}
+
+// UpdatePassword stores the new hash and revokes every token and session
+// issued before now.
+func (rp *MongoRepository) UpdatePassword(userId string, passwordHash string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"password":          passwordHash,
+		"passwordChangedAt": now,
+		"tokensRevokedAt":   now,
+	}}
+
+	result, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return rp.RevokeUserSessions(userId, ctx)
+}
+
+func (rp *MongoRepository) TokensRevokedAt(userId string, ctx context.Context) (time.Time, error) {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	var result entities.User
+	opts := options.FindOne().SetProjection(bson.M{"tokensRevokedAt": 1})
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}, opts).Decode(&result); err != nil {
+		return time.Time{}, err
+	}
+	if result.TokensRevokedAt == nil {
+		return time.Time{}, nil
+	}
+
+	return *result.TokensRevokedAt, nil
+}
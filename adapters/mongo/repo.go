@@ -9,18 +9,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Database hands out the current database; it changes when the store
+// reconnects with rotated credentials.
+type Database interface {
+	Database() *mongo.Database
+}
+
 type MongoRepository struct {
-	db *mongo.Database
+	store Database
+}
+
+func NewMongoRepository(store Database) *MongoRepository {
+	return &MongoRepository{store: store}
 }
 
-func NewMongoRepository(db *mongo.Database) *MongoRepository {
-	return &MongoRepository{db: db}
+func (rp *MongoRepository) db() *mongo.Database {
+	return rp.store.Database()
 }
 
 func (rp *MongoRepository) Register(user entities.User, ctx context.Context) error {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	_, err := coll.InsertOne(ctx, user)
 	if err != nil {
 		return err
@@ -29,7 +40,7 @@ func (rp *MongoRepository) Register(user entities.User, ctx context.Context) err
 }
 
 func (rp *MongoRepository) CheckDuplicateUser(email string, ctx context.Context) error {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	filter := bson.M{
 		"email": email,
 	}
@@ -47,7 +58,7 @@ func (rp *MongoRepository) CheckDuplicateUser(email string, ctx context.Context)
 }
 
 func (rp *MongoRepository) Login(login entities.Login, ctx context.Context) (string, error) {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	filter := bson.M{
 		"$and": []bson.M{
 			{"email": login.Email},
@@ -66,7 +77,7 @@ func (rp *MongoRepository) Login(login entities.Login, ctx context.Context) (str
 }
 
 func (rp *MongoRepository) GetUserAll(ctx context.Context) ([]entities.User, error) {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	var result []entities.User
 	cursor, err := coll.Find(ctx, bson.D{})
 	if err != nil {
@@ -82,7 +93,7 @@ func (rp *MongoRepository) GetUserAll(ctx context.Context) ([]entities.User, err
 }
 
 func (rp *MongoRepository) GetUser(userId string, ctx context.Context) (result entities.User, err error) {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	oid, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return result, fmt.Errorf("invalid user ID format: %w", err)
@@ -99,7 +110,7 @@ func (rp *MongoRepository) GetUser(userId string, ctx context.Context) (result e
 }
 
 func (rp *MongoRepository) DeleteUser(userId string, ctx context.Context) error {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	oid, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return fmt.Errorf("invalid user ID format: %w", err)
@@ -117,7 +128,7 @@ func (rp *MongoRepository) DeleteUser(userId string, ctx context.Context) error
 }
 
 func (rp *MongoRepository) UpdateUser(userId string, data entities.UpdateUserRequest, ctx context.Context) error {
-	coll := rp.db.Collection("user")
+	coll := rp.db().Collection("user")
 	oid, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return fmt.Errorf("invalid user ID format: %w", err)
@@ -128,14 +139,46 @@ func (rp *MongoRepository) UpdateUser(userId string, data entities.UpdateUserReq
 	}
 
 	update := bson.M{}
+	changes := bson.M{"$set": update}
 	if data.Email != "" {
+		// a new address has to be verified again, as in UpdateProfile
 		update["email"] = data.Email
+		update["emailVerified"] = false
+		changes["$unset"] = bson.M{"emailVerifiedAt": ""}
 	}
 
 	if data.Name != "" {
 		update["name"] = data.Name
 	}
 
-	_, err = coll.UpdateOne(ctx, filter, bson.M{"$set": update})
+	_, err = coll.UpdateOne(ctx, filter, changes)
 	return err
 }
+
+func (rp *MongoRepository) GetUserByEmail(email string, ctx context.Context) (result entities.User, err error) {
+	coll := rp.db().Collection("user")
+	filter := bson.M{
+		"email": email,
+	}
+	if err := coll.FindOne(ctx, filter).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) GetUserRole(userId string, ctx context.Context) (string, error) {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	var result entities.User
+	opts := options.FindOne().SetProjection(bson.M{"role": 1})
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}, opts).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.Role, nil
+}
@@ -0,0 +1,108 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (rp *MongoRepository) CreateSession(session entities.Session, ctx context.Context) error {
+	coll := rp.db().Collection("session")
+	_, err := coll.InsertOne(ctx, session)
+	return err
+}
+
+func (rp *MongoRepository) GetSession(sessionId string, ctx context.Context) (result entities.Session, err error) {
+	coll := rp.db().Collection("session")
+	oid, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return result, fmt.Errorf("invalid session ID format: %w", err)
+	}
+
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ListSessions returns the active sessions of a user, most recently used first.
+func (rp *MongoRepository) ListSessions(userId string, ctx context.Context) ([]entities.Session, error) {
+	coll := rp.db().Collection("session")
+	filter := bson.M{
+		"userId":    userId,
+		"revokedAt": nil,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	cursor, err := coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []entities.Session{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// TouchSession updates lastSeenAt, but only if the stored value is older than
+// the throttle interval so concurrent requests do not all write.
+func (rp *MongoRepository) TouchSession(sessionId string, interval time.Duration, ctx context.Context) error {
+	coll := rp.db().Collection("session")
+	oid, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return fmt.Errorf("invalid session ID format: %w", err)
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id":        oid,
+		"lastSeenAt": bson.M{"$lt": now.Add(-interval)},
+	}
+	_, err = coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastSeenAt": now}})
+	return err
+}
+
+func (rp *MongoRepository) RevokeSession(userId string, sessionId string, ctx context.Context) error {
+	coll := rp.db().Collection("session")
+	oid, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return fmt.Errorf("invalid session ID format: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":       oid,
+		"userId":    userId,
+		"revokedAt": nil,
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (rp *MongoRepository) RevokeUserSessions(userId string, ctx context.Context) error {
+	coll := rp.db().Collection("session")
+	filter := bson.M{
+		"userId":    userId,
+		"revokedAt": nil,
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return err
+}
@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (rp *MongoRepository) CreateEmailVerification(verification entities.EmailVerification, ctx context.Context) error {
+	coll := rp.db().Collection("email_verification")
+	_, err := coll.InsertOne(ctx, verification)
+	return err
+}
+
+// ConsumeEmailVerification marks the token as used in a single atomic update,
+// so the same token can never be redeemed twice.
+func (rp *MongoRepository) ConsumeEmailVerification(tokenId string, ctx context.Context) (result entities.EmailVerification, err error) {
+	coll := rp.db().Collection("email_verification")
+	now := time.Now()
+	filter := bson.M{
+		"_id":       tokenId,
+		"usedAt":    nil,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"usedAt": now}}
+
+	err = coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return result, fmt.Errorf("verification token is invalid, expired or already used")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (rp *MongoRepository) InvalidateEmailVerifications(userId string, ctx context.Context) error {
+	coll := rp.db().Collection("email_verification")
+	filter := bson.M{
+		"userId": userId,
+		"usedAt": nil,
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"usedAt": time.Now()}})
+	return err
+}
+
+func (rp *MongoRepository) MarkEmailVerified(userId string, email string, ctx context.Context) error {
+	coll := rp.db().Collection("user")
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	// the email must still match, otherwise the token was issued for an old address
+	filter := bson.M{
+		"_id":   oid,
+		"email": email,
+	}
+	update := bson.M{"$set": bson.M{"emailVerified": true, "emailVerifiedAt": time.Now()}}
+
+	result, err := coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("verification token does not match the current email")
+	}
+
+	return nil
+}
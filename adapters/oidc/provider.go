@@ -0,0 +1,330 @@
+package adapters
+
+import (
+	"backend-challenge/pkg/tracing"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type IDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified Bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// Bool is a claim some providers send as "true" or "false" instead of a JSON
+// boolean.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*b = false
+	case bool:
+		*b = Bool(v)
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		*b = Bool(parsed)
+	default:
+		return fmt.Errorf("%s is not a boolean", data)
+	}
+	return nil
+}
+
+// Provider is an OpenID Connect relying party for a single issuer. The
+// discovery document is loaded on first use and the JWKS is refreshed when an
+// unknown key id shows up.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+}
+
+func NewProvider(cfg Config) *Provider {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second, Transport: tracing.Transport(nil)}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{cfg: cfg, client: client}
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.cfg.ClientID)
+	values.Set("redirect_uri", p.cfg.RedirectURL)
+	values.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + values.Encode(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (TokenResponse, error) {
+	var result TokenResponse
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return result, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("oidc token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("oidc token response: %w", err)
+	}
+	if result.IDToken == "" {
+		return result, errors.New("oidc token response has no id_token")
+	}
+
+	return result, nil
+}
+
+// VerifyIDToken checks signature, issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (IDTokenClaims, error) {
+	var claims IDTokenClaims
+	if _, err := p.discover(ctx); err != nil {
+		return claims, err
+	}
+
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return claims, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Subject == "" {
+		return claims, errors.New("invalid id_token: missing sub")
+	}
+	if claims.Nonce != nonce {
+		return claims, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var doc discoveryDocument
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if doc.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document is missing endpoints")
+	}
+
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	// unknown kid: the provider may have rotated keys, but do not hammer it
+	if time.Since(p.keysAt) < time.Minute && p.keys != nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
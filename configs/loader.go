@@ -0,0 +1,509 @@
+package configs
+
+import (
+	"backend-challenge/configs/store"
+	"backend-challenge/pkg/certs"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/pkg/metrics"
+	"backend-challenge/pkg/tracing"
+	"backend-challenge/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sethvargo/go-envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Where a value came from, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Value is one resolved setting, as the string it was given in.
+type Value struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+	File   string // the file it was read from, for KEY_FILE settings
+}
+
+// Snapshot is everything Load resolved, in declaration order.
+type Snapshot struct {
+	Files  []string
+	Values []Value
+}
+
+// ConfigError lists every problem found while loading, so they can all be
+// fixed in one go.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Loader reads the configuration in layers: defaults from the env tags, then
+// YAML or TOML files, then environment variables, then command line flags,
+// each overriding the one before. In files and the environment any key may be
+// given as KEY_FILE instead, naming a file that holds the value (Docker and
+// Kubernetes secrets).
+type Loader struct {
+	Args []string           // flags, e.g. --config app.yaml --app-port 9090
+	Env  envconfig.Lookuper // nil reads the process environment
+}
+
+// sections are the config structs the rest of the code reads. Keys are the
+// env names of their fields.
+func sections() []interface{} {
+	return []interface{}{
+		App,
+		Auth,
+		OIDC,
+		OAuth,
+		CORS,
+		Admin,
+		utils.JWT,
+		store.M,
+		logging.L,
+		mailer.M,
+		metrics.M,
+		tracing.T,
+		certs.C,
+	}
+}
+
+type configKey struct {
+	name      string
+	def       string
+	delimiter string
+	secret    bool // tagged json:"-"
+	section   int  // index into the targets
+	field     int
+}
+
+func configKeys(targets []interface{}) []configKey {
+	var keys []configKey
+	for section, target := range targets {
+		t := reflect.TypeOf(target).Elem()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("env")
+			if tag == "" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			key := configKey{name: name, delimiter: ",", secret: field.Tag.Get("json") == "-", section: section, field: i}
+			for opts != "" {
+				// default takes the rest of the tag, commas included
+				if def, ok := strings.CutPrefix(opts, "default="); ok {
+					key.def = def
+					break
+				}
+				var opt string
+				opt, opts, _ = strings.Cut(opts, ",")
+				if delimiter, ok := strings.CutPrefix(opt, "delimiter="); ok {
+					key.delimiter = delimiter
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Load resolves the configuration from the process environment and args.
+func Load(ctx context.Context, args []string) (*Snapshot, error) {
+	return Loader{Args: args}.Load(ctx)
+}
+
+// Load fills the config sections. Nothing is changed unless every value
+// decodes and every section validates; otherwise the error is a ConfigError.
+// The snapshot is returned either way, so config print can show what was
+// read. Flag parsing errors, including flag.ErrHelp, are returned as is.
+func (l Loader) Load(ctx context.Context) (*Snapshot, error) {
+	snapshot, fresh, err := l.read(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+	for i, target := range sections() {
+		reflect.ValueOf(target).Elem().Set(fresh[i].Elem())
+	}
+	return snapshot, nil
+}
+
+// read resolves and validates the configuration into fresh copies of the
+// sections, without touching the running ones.
+func (l Loader) read(ctx context.Context) (*Snapshot, []reflect.Value, error) {
+	env := l.Env
+	if env == nil {
+		env = envconfig.OsLookuper()
+	}
+	targets := sections()
+	keys := configKeys(targets)
+	known := make(map[string]configKey, len(keys))
+	for _, key := range keys {
+		known[key.name] = key
+	}
+
+	flags, files, err := parseFlags(l.Args, keys, io.Discard)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		if value, ok := env.Lookup("CONFIG_FILE"); ok && value != "" {
+			files = strings.Split(value, ",")
+		}
+	}
+
+	var problems []string
+	fileValues := map[string]string{}
+	for _, path := range files {
+		values, errs := readConfigFile(path, known)
+		problems = append(problems, errs...)
+		for k, v := range values {
+			fileValues[k] = v
+		}
+	}
+
+	snapshot := &Snapshot{Files: files}
+	resolved := map[string]string{}
+	for _, key := range keys {
+		value := Value{Key: key.name, Value: key.def, Source: SourceDefault, Secret: key.secret}
+		if v, ok := flags[key.name]; ok {
+			value.Value, value.Source = v, SourceFlag
+		} else if err := layerValue(&value, SourceEnv, env); err != nil {
+			problems = append(problems, err.Error())
+		} else if value.Source == SourceDefault {
+			if err := layerValue(&value, SourceFile, envconfig.MapLookuper(fileValues)); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		if value.Source != SourceDefault {
+			resolved[key.name] = value.Value
+		}
+		snapshot.Values = append(snapshot.Values, value)
+	}
+
+	// decode into fresh copies, so a bad value leaves the running config alone
+	lookuper := envconfig.MapLookuper(resolved)
+	fresh := make([]reflect.Value, len(targets))
+	for i, target := range targets {
+		fresh[i] = reflect.New(reflect.TypeOf(target).Elem())
+		if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: fresh[i].Interface(), Lookuper: lookuper}); err != nil {
+			// report every bad value, then validate the rest without them
+			bad, decodeErrs := decodeProblems(ctx, target, resolved)
+			problems = append(problems, decodeErrs...)
+			fresh[i] = reflect.New(reflect.TypeOf(target).Elem())
+			if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: fresh[i].Interface(), Lookuper: without(resolved, bad)}); err != nil {
+				continue
+			}
+		}
+		if v, ok := fresh[i].Interface().(interface{ Validate() error }); ok {
+			problems = append(problems, splitErrors(v.Validate())...)
+		}
+	}
+	// METRICS_ADDR stands in for ADMIN_ADDR from another section
+	copies := freshCopies(targets, fresh)
+	if admin := FreshSection(copies, Admin); admin.Addr == "" {
+		if err := admin.requireToken("METRICS_ADDR", FreshSection(copies, metrics.M).Addr); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return snapshot, nil, &ConfigError{Problems: problems}
+	}
+	return snapshot, fresh, nil
+}
+
+func freshCopies(targets []interface{}, fresh []reflect.Value) Fresh {
+	copies := make(Fresh, len(targets))
+	for i, target := range targets {
+		copies[target] = fresh[i].Interface()
+	}
+	return copies
+}
+
+// layerValue sets value from KEY or KEY_FILE in one layer, if either is there.
+func layerValue(value *Value, source string, layer envconfig.Lookuper) error {
+	v, direct := layer.Lookup(value.Key)
+	path, indirect := layer.Lookup(value.Key + "_FILE")
+	switch {
+	case direct && indirect:
+		return fmt.Errorf("%s and %s_FILE are both set in %s", value.Key, value.Key, source)
+	case direct:
+		value.Value, value.Source = v, source
+	case indirect:
+		secret, err := ReadSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", value.Key, err)
+		}
+		value.Value, value.Source, value.File = secret, source, path
+	}
+	return nil
+}
+
+// ReadSecretFile reads a KEY_FILE value, without the trailing newline most
+// secret files end with.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// decodeProblems decodes a section one key at a time and returns the keys
+// whose values do not decode, with why.
+func decodeProblems(ctx context.Context, target interface{}, resolved map[string]string) (map[string]bool, []string) {
+	bad := map[string]bool{}
+	var problems []string
+	for _, key := range configKeys([]interface{}{target}) {
+		value, ok := resolved[key.name]
+		if !ok {
+			continue
+		}
+		probe := reflect.New(reflect.TypeOf(target).Elem()).Interface()
+		lookuper := envconfig.MapLookuper(map[string]string{key.name: value})
+		if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: probe, Lookuper: lookuper}); err != nil {
+			// envconfig prefixes the Go field name; the key is what users know
+			if inner := errors.Unwrap(err); inner != nil {
+				err = inner
+			}
+			bad[key.name] = true
+			problems = append(problems, fmt.Sprintf("%s: %v", key.name, err))
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, fmt.Sprintf("%T could not be decoded", target))
+	}
+	return bad, problems
+}
+
+func without(values map[string]string, drop map[string]bool) envconfig.Lookuper {
+	kept := make(map[string]string, len(values))
+	for k, v := range values {
+		if !drop[k] {
+			kept[k] = v
+		}
+	}
+	return envconfig.MapLookuper(kept)
+}
+
+func splitErrors(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems []string
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, splitErrors(e)...)
+		}
+		return problems
+	}
+	return []string{err.Error()}
+}
+
+// parseFlags accepts --config (repeatable) and one flag per key, named after
+// it: APP_PORT is --app-port.
+func parseFlags(args []string, keys []configKey, output io.Writer) (map[string]string, []string, error) {
+	fs := flag.NewFlagSet("backend-challenge", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	var files []string
+	fs.Func("config", "YAML or TOML config file, may be repeated (env CONFIG_FILE)", func(path string) error {
+		files = append(files, path)
+		return nil
+	})
+	values := map[string]string{}
+	for _, key := range keys {
+		name := key.name
+		fs.Func(flagName(name), "overrides "+name, func(value string) error {
+			values[name] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return values, files, nil
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// Usage writes the flags Load accepts.
+func Usage(w io.Writer) {
+	parseFlags([]string{"-h"}, configKeys(sections()), w)
+}
+
+// readConfigFile flattens a YAML or TOML file into env keys: nested keys are
+// joined with "_" and upper-cased, so
+//
+//	app:
+//	  port: 9090
+//
+// sets APP_PORT. Lists become the key's delimited form, lists of objects
+// JSON (LOG_SINKS).
+func readConfigFile(path string, known map[string]configKey) (map[string]string, []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	var tree map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, []string{fmt.Sprintf("%s: config files must be .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	values := map[string]string{}
+	var problems []string
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		if base, ok := strings.CutSuffix(prefix, "_FILE"); ok && known[base].name != "" {
+			values[prefix] = fmt.Sprint(node)
+			return
+		}
+		if key, ok := known[prefix]; ok {
+			value, err := fileValue(node, key.delimiter)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s: %v", path, prefix, err))
+				return
+			}
+			values[prefix] = value
+			return
+		}
+		children, ok := node.(map[string]interface{})
+		if !ok || prefix == "" && len(children) == 0 {
+			if prefix != "" {
+				problems = append(problems, fmt.Sprintf("%s: unknown key %s", path, fileKey(prefix)))
+			}
+			return
+		}
+		names := make([]string, 0, len(children))
+		for name := range children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			walk(key, children[name])
+		}
+	}
+	walk("", tree)
+	return values, problems
+}
+
+func fileValue(node interface{}, delimiter string) (string, error) {
+	switch v := node.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case map[string]interface{}, []map[string]interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				data, err := json.Marshal(v)
+				return string(data), err
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, delimiter), nil
+	}
+	return fmt.Sprint(node), nil
+}
+
+func fileKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", ".")
+}
+
+// Print writes the snapshot as a YAML config file, grouped by the first word
+// of each key and annotated with where each value came from. Secrets are
+// replaced and passwords in URLs masked.
+func (s *Snapshot) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	groups := map[string]*yaml.Node{}
+	for _, value := range s.Values {
+		comment := value.Source
+		if value.File != "" {
+			comment += " " + value.File
+		}
+		group, name, _ := strings.Cut(strings.ToLower(value.Key), "_")
+		node, ok := groups[group]
+		if !ok {
+			node = &yaml.Node{Kind: yaml.MappingNode}
+			groups[group] = node
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: group}, node)
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redactValue(value), LineComment: comment},
+		)
+	}
+
+	if len(s.Files) > 0 {
+		root.HeadComment = "files: " + strings.Join(s.Files, ", ")
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func redactValue(value Value) string {
+	if value.Value == "" {
+		return ""
+	}
+	if value.Secret {
+		return "[REDACTED]"
+	}
+	if u, err := url.Parse(value.Value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+			return u.String()
+		}
+	}
+	return value.Value
+}
+
+// IsHelp reports whether err is a request for usage rather than a failure.
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
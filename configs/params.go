@@ -1,16 +1,23 @@
 package configs
 
 import (
-	"backend-challenge/pkg/logging"
-	"context"
+	"backend-challenge/pkg/metrics"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 	"time"
-
-	"github.com/sethvargo/go-envconfig"
 )
 
 var (
-	App = new(config)
+	App   = new(config)
+	Auth  = new(authConfig)
+	OIDC  = new(oidcConfig)
+	OAuth = new(oauthConfig)
+	CORS  = new(corsConfig)
+	Admin = new(adminConfig)
 )
 
 type config struct {
@@ -18,20 +25,137 @@ type config struct {
 	Port    string        `env:"APP_PORT,default=8080" json:",omitempty"`
 	Timeout time.Duration `env:"APP_TIMEOUT,default=1m" json:",omitempty"`
 	Prefix  string        `env:"APP_PREFIX,default=/" json:",omitempty"`
+
+	RequestIDHeader string        `env:"APP_REQUEST_ID_HEADER,default=X-Request-ID" json:",omitempty"`
+	HealthTimeout   time.Duration `env:"APP_HEALTH_TIMEOUT,default=2s" json:",omitempty"`
+	ShutdownDelay   time.Duration `env:"APP_SHUTDOWN_DELAY,default=5s" json:",omitempty"`
+	ShutdownTimeout time.Duration `env:"APP_SHUTDOWN_TIMEOUT,default=20s" json:",omitempty"`
+
+	// SecretsWatchInterval is how often KEY_FILE secrets are re-read; 0 turns
+	// rotation off.
+	SecretsWatchInterval time.Duration `env:"APP_SECRETS_WATCH_INTERVAL,default=30s" json:",omitempty"`
+	UserCountInterval    time.Duration `env:"APP_USER_COUNT_INTERVAL,default=10s" json:",omitempty"`
 }
 
-func SetEnv(ctx context.Context) error {
-	logger := logging.FromContext(ctx).Named("set environment")
-	configs := []interface{}{
-		App,
-		logging.L,
+func (c *config) Validate() error {
+	var errs []error
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("APP_PORT %q is not a port number", c.Port))
 	}
-	for _, cfg := range configs {
-		if err := envconfig.Process(ctx, cfg); err != nil {
-			logger.Warnw("failed to process config file: %v", err)
-			return fmt.Errorf("failed to process config file: %v", err)
-		}
+	if c.UserCountInterval <= 0 {
+		errs = append(errs, fmt.Errorf("APP_USER_COUNT_INTERVAL must be positive"))
 	}
+	return errors.Join(errs...)
+}
+
+type authConfig struct {
+	RequireVerifiedEmail bool          `env:"AUTH_REQUIRE_VERIFIED_EMAIL,default=false" json:",omitempty"`
+	VerificationTTL      time.Duration `env:"AUTH_VERIFICATION_TTL,default=24h" json:",omitempty"`
+	VerifyEmailURL       string        `env:"AUTH_VERIFY_EMAIL_URL,default=http://localhost:8080/auth/verify-email" json:",omitempty"`
+	PasswordResetTTL     time.Duration `env:"AUTH_PASSWORD_RESET_TTL,default=30m" json:",omitempty"`
+	ResetPasswordURL     string        `env:"AUTH_RESET_PASSWORD_URL,default=http://localhost:8080/auth/reset-password" json:",omitempty"`
+	MFAIssuer            string        `env:"AUTH_MFA_ISSUER,default=backend-challenge" json:",omitempty"`
+	MFAChallengeTTL      time.Duration `env:"AUTH_MFA_CHALLENGE_TTL,default=5m" json:",omitempty"`
+	SessionTouchInterval time.Duration `env:"AUTH_SESSION_TOUCH_INTERVAL,default=1m" json:",omitempty"`
+	DeletionGracePeriod  time.Duration `env:"AUTH_DELETION_GRACE_PERIOD,default=720h" json:",omitempty"`
+	PurgeInterval        time.Duration `env:"AUTH_PURGE_INTERVAL,default=1h" json:",omitempty"`
+	CookieMode           bool          `env:"AUTH_COOKIE_MODE,default=false" json:",omitempty"`
+	CookieName           string        `env:"AUTH_COOKIE_NAME,default=access_token" json:",omitempty"`
+	CookieDomain         string        `env:"AUTH_COOKIE_DOMAIN" json:",omitempty"`
+	CookieSecure         bool          `env:"AUTH_COOKIE_SECURE,default=true" json:",omitempty"`
+	CookieSameSite       string        `env:"AUTH_COOKIE_SAMESITE,default=Lax" json:",omitempty"`
+	CSRFCookieName       string        `env:"AUTH_CSRF_COOKIE_NAME,default=csrf_token" json:",omitempty"`
+	CSRFHeader           string        `env:"AUTH_CSRF_HEADER,default=X-CSRF-Token" json:",omitempty"`
+}
+
+type oidcConfig struct {
+	Issuer       string        `env:"OIDC_ISSUER" json:",omitempty"`
+	ClientID     string        `env:"OIDC_CLIENT_ID" json:",omitempty"`
+	ClientSecret string        `env:"OIDC_CLIENT_SECRET" json:"-"`
+	RedirectURL  string        `env:"OIDC_REDIRECT_URL,default=http://localhost:8080/auth/oidc/callback" json:",omitempty"`
+	Scopes       []string      `env:"OIDC_SCOPES,default=openid,email,profile" json:",omitempty"`
+	ProviderName string        `env:"OIDC_PROVIDER_NAME,default=oidc" json:",omitempty"`
+	StateTTL     time.Duration `env:"OIDC_STATE_TTL,default=10m" json:",omitempty"`
+}
 
+func (c *oidcConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
+
+type oauthConfig struct {
+	AccessTokenTTL  time.Duration `env:"OAUTH_ACCESS_TOKEN_TTL,default=1h" json:",omitempty"`
+	RefreshTokenTTL time.Duration `env:"OAUTH_REFRESH_TOKEN_TTL,default=720h" json:",omitempty"`
+	CodeTTL         time.Duration `env:"OAUTH_CODE_TTL,default=5m" json:",omitempty"`
+}
+
+type corsConfig struct {
+	AllowOrigins     []string      `env:"CORS_ALLOW_ORIGINS,default=*" json:",omitempty"`
+	AllowHeaders     []string      `env:"CORS_ALLOW_HEADERS,default=Origin,Content-Type,Accept,Authorization,X-API-Key,X-CSRF-Token" json:",omitempty"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS,default=false" json:",omitempty"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE,default=0s" json:",omitempty"`
+}
+
+// Validate rejects combinations browsers refuse: credentials cannot be
+// allowed for the "*" origin.
+func (c *corsConfig) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			return fmt.Errorf("CORS_ALLOW_CREDENTIALS requires explicit CORS_ALLOW_ORIGINS, not *")
+		}
+	}
 	return nil
 }
+
+type adminConfig struct {
+	// Addr is where the admin listener binds: host:port, or unix:/path for a
+	// unix socket. Empty turns it off.
+	Addr       string `env:"ADMIN_ADDR" json:",omitempty"`
+	SocketMode string `env:"ADMIN_SOCKET_MODE,default=0660" json:",omitempty"`
+	// Token, when set, must be sent as a bearer token on every admin request.
+	Token string `env:"ADMIN_TOKEN" json:"-"`
+}
+
+// Address is ADMIN_ADDR, or the older METRICS_ADDR when only that is set.
+func (c *adminConfig) Address() string {
+	if c.Addr == "" {
+		return metrics.M.Addr
+	}
+	return c.Addr
+}
+
+func (c *adminConfig) Validate() error {
+	var errs []error
+	if _, err := c.socketMode(); err != nil {
+		errs = append(errs, fmt.Errorf("ADMIN_SOCKET_MODE %q is not an octal file mode", c.SocketMode))
+	}
+	errs = append(errs, c.requireToken("ADMIN_ADDR", c.Addr))
+	return errors.Join(errs...)
+}
+
+// requireToken refuses an admin listener anyone on the network could reach
+// without ADMIN_TOKEN; only loopback addresses and unix sockets may go
+// without one.
+func (c *adminConfig) requireToken(key, addr string) error {
+	if addr == "" || c.Token != "" || strings.HasPrefix(addr, "unix:") {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s %q must be host:port or unix:/path", key, addr)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("ADMIN_TOKEN is required when %s %q is not a loopback address or a unix socket", key, addr)
+}
+
+func (c *adminConfig) socketMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q", c.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
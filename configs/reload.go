@@ -0,0 +1,175 @@
+package configs
+
+import (
+	"backend-challenge/pkg/health"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ReloadApplied   = "applied"
+	ReloadUnchanged = "unchanged"
+	ReloadRejected  = "rejected"
+	ReloadFailed    = "failed"
+)
+
+// Reloader re-reads the configuration and applies the changes that can take
+// effect without a restart. A reload that changes anything else is rejected
+// as a whole, so the running config never mixes two versions.
+//
+// The config sections are never written after startup, since request
+// goroutines read them without locks. Live values are handed to their
+// appliers, which keep them in holders that are safe to swap, such as
+// logging.Levels or a middlewares.Reloadable; the sections keep the values
+// the process started with.
+type Reloader struct {
+	loader Loader
+	health *health.Registry
+	logger *zap.SugaredLogger
+
+	mu      sync.Mutex
+	current *Snapshot
+	live    map[string]int // key -> index into appliers
+	apply   []func(Fresh)
+	status  health.ConfigStatus
+}
+
+// NewReloader starts at version 1 with snapshot, the result of the first Load.
+func NewReloader(loader Loader, snapshot *Snapshot, registry *health.Registry, logger *zap.SugaredLogger) *Reloader {
+	r := &Reloader{
+		loader:  loader,
+		health:  registry,
+		logger:  logger,
+		current: snapshot,
+		live:    map[string]int{},
+		status:  health.ConfigStatus{Version: 1, LoadedAt: time.Now()},
+	}
+	registry.SetConfig(r.status)
+	return r
+}
+
+// Fresh is the configuration read by a reload, one copy per config section.
+type Fresh map[interface{}]interface{}
+
+// FreshSection returns the reloaded copy of section, e.g.
+// FreshSection(fresh, CORS).AllowOrigins.
+func FreshSection[T any](fresh Fresh, section T) T {
+	return fresh[section].(T)
+}
+
+// Live marks keys as safe to change at runtime. When any of them changed,
+// apply is called once with the reloaded config to put it into effect.
+func (r *Reloader) Live(apply func(Fresh), keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply = append(r.apply, apply)
+	for _, key := range keys {
+		r.live[key] = len(r.apply) - 1
+	}
+}
+
+// Reload reads the configuration again and applies it if only live keys
+// changed. Settings read from KEY_FILE are left to the SecretWatcher.
+func (r *Reloader) Reload(ctx context.Context) (health.ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := health.ReloadResult{At: time.Now()}
+	snapshot, fresh, err := r.loader.read(ctx)
+	if err != nil {
+		result.Status, result.Error = ReloadFailed, err.Error()
+		r.logger.Errorw("config reload failed, keeping the running config", "error", err)
+		return r.finish(result), err
+	}
+
+	changed := diffSnapshots(r.current, snapshot)
+	result.Changed = changed
+	var restart []string
+	for _, key := range changed {
+		if _, ok := r.live[key]; !ok {
+			restart = append(restart, key)
+		}
+	}
+	switch {
+	case len(changed) == 0:
+		result.Status = ReloadUnchanged
+		r.logger.Info("config reload found no changes")
+		return r.finish(result), nil
+	case len(restart) > 0:
+		err := fmt.Errorf("%s can only change with a restart", strings.Join(restart, ", "))
+		result.Status, result.Error = ReloadRejected, err.Error()
+		r.logger.Warnw("config reload rejected, nothing was applied", "restart_required", restart, "changed", changed, "error", err)
+		return r.finish(result), err
+	}
+
+	copies := freshCopies(sections(), fresh)
+	appliers := map[int]bool{}
+	for _, name := range changed {
+		appliers[r.live[name]] = true
+	}
+	for i := range r.apply {
+		if appliers[i] {
+			r.apply[i](copies)
+		}
+	}
+
+	r.current = snapshot
+	r.status.Version++
+	r.status.LoadedAt = result.At
+	result.Status = ReloadApplied
+	r.logger.Infow("config reloaded", "version", r.status.Version, "changed", changed)
+	return r.finish(result), nil
+}
+
+func (r *Reloader) finish(result health.ReloadResult) health.ReloadResult {
+	r.status.LastReload = &result
+	r.health.SetConfig(r.status)
+	return result
+}
+
+// Status is the current version and the outcome of the last reload.
+func (r *Reloader) Status() health.ConfigStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Run reloads on every signal until ctx is done.
+func (r *Reloader) Run(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// diffSnapshots lists the keys whose value changed, skipping those read from
+// files on either side.
+func diffSnapshots(old, new *Snapshot) []string {
+	previous := make(map[string]Value, len(old.Values))
+	for _, v := range old.Values {
+		previous[v.Key] = v
+	}
+	var changed []string
+	for _, v := range new.Values {
+		before := previous[v.Key]
+		if v.File != "" || before.File != "" {
+			continue
+		}
+		if v.Value != before.Value {
+			changed = append(changed, v.Key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
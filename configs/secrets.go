@@ -0,0 +1,91 @@
+package configs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SecretWatcher re-reads the files behind KEY_FILE settings and hands changed
+// values to the handler registered for the key, so mounted secrets can be
+// rotated without a restart.
+type SecretWatcher struct {
+	interval time.Duration
+	logger   *zap.SugaredLogger
+	files    map[string]string // key -> path
+	values   map[string]string // last value applied
+	handlers map[string]func(ctx context.Context, value string) error
+}
+
+func NewSecretWatcher(snapshot *Snapshot, interval time.Duration, logger *zap.SugaredLogger) *SecretWatcher {
+	w := &SecretWatcher{
+		interval: interval,
+		logger:   logger,
+		files:    map[string]string{},
+		values:   map[string]string{},
+		handlers: map[string]func(context.Context, string) error{},
+	}
+	for _, v := range snapshot.Values {
+		if v.File != "" {
+			w.files[v.Key] = v.File
+			w.values[v.Key] = v.Value
+		}
+	}
+	return w
+}
+
+// Handle registers fn for key. Keys not read from a file are never watched.
+func (w *SecretWatcher) Handle(key string, fn func(ctx context.Context, value string) error) {
+	w.handlers[key] = fn
+}
+
+// Watching reports whether any registered key comes from a file.
+func (w *SecretWatcher) Watching() bool {
+	for key := range w.handlers {
+		if _, ok := w.files[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reads every watched file once and applies the ones that changed. A
+// failed handler is retried on the next check.
+func (w *SecretWatcher) Check(ctx context.Context) {
+	for key, fn := range w.handlers {
+		path, ok := w.files[key]
+		if !ok {
+			continue
+		}
+		value, err := ReadSecretFile(path)
+		if err != nil {
+			// a secret being replaced may briefly be missing
+			w.logger.Warnw("failed to read secret file", "key", key, "path", path, "error", err)
+			continue
+		}
+		if value == w.values[key] {
+			continue
+		}
+		if err := fn(ctx, value); err != nil {
+			w.logger.Errorw("failed to apply rotated secret", "key", key, "error", err)
+			continue
+		}
+		w.values[key] = value
+		w.logger.Infow("secret rotated", "key", key)
+	}
+}
+
+// Run checks every interval until ctx is done.
+func (w *SecretWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Check(ctx)
+		}
+	}
+}
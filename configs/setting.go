@@ -2,32 +2,57 @@ package configs
 
 import (
 	"backend-challenge/configs/store"
+	"backend-challenge/entities"
 	"backend-challenge/middlewares"
+	"backend-challenge/pkg/certs"
+	"backend-challenge/pkg/health"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/pkg/metrics"
+	"backend-challenge/pkg/shutdown"
+	"backend-challenge/pkg/tracing"
+	"backend-challenge/utils"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"go.uber.org/zap"
 )
 
 type Setting struct {
 	App     *fiber.App
+	Admin   *fiber.App // admin listener, nil unless ADMIN_ADDR is set
 	Logger  *zap.SugaredLogger
 	DBMongo *store.MongoStore
+	Mailer  mailer.Mailer
+	Health  *health.Registry
+	Workers *shutdown.Workers
+
+	stopTracing       func(context.Context) error
+	tls               *tls.Config // nil serves plain HTTP
+	certs             *certs.Reloader
+	cors              *middlewares.Reloadable
+	userCountInterval atomic.Int64
 }
 
 func NewApp(logger *zap.SugaredLogger) *Setting {
 	return &Setting{Logger: logger}
 }
 
+// SetApp builds the app from the loaded configuration; call Load first.
 func (c *Setting) SetApp(ctx context.Context) error {
 	c.Logger.Named("backend-chellenge")
 
@@ -39,25 +64,34 @@ func (c *Setting) SetApp(ctx context.Context) error {
 		JSONDecoder:   json.Unmarshal,
 	})
 
-	if err := SetEnv(ctx); err != nil {
+	c.Health = health.NewRegistry(App.HealthTimeout)
+	c.Workers = shutdown.NewWorkers(ctx)
+
+	stopTracing, err := tracing.Setup(ctx, tracing.T)
+	if err != nil {
 		return err
 	}
+	c.stopTracing = stopTracing
 
-	cfg := cors.Config{
-		AllowMethods: strings.Join([]string{
-			fiber.MethodGet,
-			fiber.MethodPost,
-			fiber.MethodHead,
-			fiber.MethodPut,
-			fiber.MethodDelete,
-			fiber.MethodPatch,
-		}, ","),
+	if err := CORS.Validate(); err != nil {
+		return err
 	}
+	c.cors = middlewares.NewReloadable(corsHandler(CORS))
+	c.userCountInterval.Store(int64(App.UserCountInterval))
 
+	c.App.Use(middlewares.RequestIDMiddleware(App.RequestIDHeader))
 	c.App.Use(recover.New(recover.Config{EnableStackTrace: true}))
 	c.App.Use(helmet.New())
-	c.App.Use(cors.New(cfg))
-	c.App.Use(middlewares.LoggerMiddleware(c.Logger))
+	c.App.Use(c.cors.Handler())
+	c.App.Use(middlewares.TracingMiddleware())
+	c.App.Use(middlewares.LoggerMiddleware(c.Logger, logging.L.RequestFilter()))
+	c.SetAdmin()
+	if metrics.M.Enabled {
+		c.App.Use(middlewares.MetricsMiddleware())
+		if c.Admin == nil {
+			c.App.Get(metrics.M.Path, metrics.Handler())
+		}
+	}
 	c.App.Use(logger.New(logger.Config{
 		Format:     "${blue}${time} ${yellow}${status} - ${red}${latency} ${cyan}${method} ${path} ${green} ${ip} ${ua} ${reset}\n",
 		TimeFormat: "02-Jan-2006 15:04:05",
@@ -72,35 +106,218 @@ func (c *Setting) SetApp(ctx context.Context) error {
 	}
 
 	c.DBMongo = mongodb
+	if err := store.Migrate(ctx, mongodb.Database()); err != nil {
+		return err
+	}
+	c.Health.Register("mongo", mongodb.Ping)
+	c.Health.Register("migrations", mongodb.CheckMigrations)
+
+	c.Mailer, err = mailer.New(mailer.M)
+	if err != nil {
+		return err
+	}
+
+	if certs.C.Enabled() {
+		c.certs, err = certs.NewReloader(certs.C.CertFile, certs.C.KeyFile, c.Logger.Named("tls"))
+		if err != nil {
+			return err
+		}
+		if c.tls, err = certs.C.ServerConfig(c.certs); err != nil {
+			return err
+		}
+		c.Health.Register("tls", c.certs.Expiry)
+	}
 	return nil
 }
 
 func (c *Setting) RunApp(ctx context.Context) <-chan error {
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
 
 	go func() {
-		err := c.App.Listen(fmt.Sprintf(":%v", App.Port))
-		if err != nil {
+		if err := c.listen(fmt.Sprintf(":%v", App.Port)); err != nil {
 			errChan <- fmt.Errorf("fiber listen error: %w", err)
 		}
 	}()
+	if c.certs != nil && certs.C.ReloadInterval > 0 {
+		c.Go("tls_reloader", func(ctx context.Context) {
+			c.certs.Run(ctx, certs.C.ReloadInterval)
+		})
+	}
+
+	if c.Admin != nil {
+		go func() {
+			if err := c.listenAdmin(Admin.Address()); err != nil {
+				errChan <- fmt.Errorf("admin listen error: %w", err)
+			}
+		}()
+	}
 
 	return errChan
 }
 
-func (c *Setting) StopApp(ctx context.Context) error {
-	if err := c.App.Shutdown(); err != nil {
-		return fmt.Errorf("error app failed to stop : %s", err)
+// listen serves App on addr, over TLS when a certificate is configured.
+func (c *Setting) listen(addr string) error {
+	if c.tls == nil {
+		return c.App.Listen(addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
 	}
+	return c.App.Listener(tls.NewListener(ln, c.tls))
+}
 
-	// Disconnect Mongo
-	if c.DBMongo != nil {
-		ctxTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-		if err := c.DBMongo.Client.Disconnect(ctxTimeout); err != nil {
-			return fmt.Errorf("mongo disconnect error: %w", err)
+// SetAdmin builds the admin listener when ADMIN_ADDR (or METRICS_ADDR) is
+// set, with metrics and pprof; routers add the rest. SetApp calls it.
+func (c *Setting) SetAdmin() {
+	if Admin.Address() == "" {
+		return
+	}
+	if Admin.Addr == "" {
+		c.Logger.Warn("METRICS_ADDR is deprecated, use ADMIN_ADDR")
+	}
+	c.Admin = fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		JSONEncoder:           json.Marshal,
+		JSONDecoder:           json.Unmarshal,
+	})
+	c.Admin.Use(recover.New())
+	c.Admin.Use(middlewares.AdminMiddleware(Admin.Token))
+	c.Admin.Use(pprof.New())
+	if metrics.M.Enabled {
+		c.Admin.Get(metrics.M.Path, metrics.Handler())
+	}
+}
+
+// listenAdmin serves Admin on a TCP address or, for unix:/path, on a unix
+// socket. A socket file left by a crashed instance is replaced.
+func (c *Setting) listenAdmin(addr string) error {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return c.Admin.Listen(addr)
+	}
+	mode, err := Admin.socketMode()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return err
+	}
+	return c.Admin.Listener(ln)
+}
+
+// Go runs a background worker that keeps going while requests drain, is
+// awaited by StopApp and is reported by the readiness probe.
+func (c *Setting) Go(name string, fn func(ctx context.Context)) {
+	worker := c.Health.Worker(name)
+	worker.Start()
+	c.Workers.Go(func(ctx context.Context) {
+		defer worker.Stop()
+		fn(ctx)
+	})
+}
+
+func corsHandler(cfg *corsConfig) fiber.Handler {
+	return cors.New(cors.Config{
+		AllowOrigins: strings.Join(cfg.AllowOrigins, ","),
+		AllowMethods: strings.Join([]string{
+			fiber.MethodGet,
+			fiber.MethodPost,
+			fiber.MethodHead,
+			fiber.MethodPut,
+			fiber.MethodDelete,
+			fiber.MethodPatch,
+		}, ","),
+		AllowHeaders:     strings.Join(cfg.AllowHeaders, ","),
+		ExposeHeaders:    entities.RequestIDHeader,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+	})
+}
+
+// UserCountInterval is APP_USER_COUNT_INTERVAL as of the last reload.
+func (c *Setting) UserCountInterval() time.Duration {
+	return time.Duration(c.userCountInterval.Load())
+}
+
+// Reloader applies config reloads to the running app. The log level, CORS
+// and the user count interval change live; anything else needs a restart.
+func (c *Setting) Reloader(loader Loader, snapshot *Snapshot) *Reloader {
+	r := NewReloader(loader, snapshot, c.Health, c.Logger.Named("config"))
+	r.Live(func(fresh Fresh) {
+		level, _ := logging.ParseLevel(FreshSection(fresh, logging.L).LogLevel)
+		logging.Levels.SetBase(level)
+	}, "LOG_LEVEL")
+	r.Live(func(fresh Fresh) {
+		c.cors.Store(corsHandler(FreshSection(fresh, CORS)))
+	}, "CORS_ALLOW_ORIGINS", "CORS_ALLOW_HEADERS", "CORS_ALLOW_CREDENTIALS", "CORS_MAX_AGE")
+	r.Live(func(fresh Fresh) {
+		c.userCountInterval.Store(int64(FreshSection(fresh, App).UserCountInterval))
+	}, "APP_USER_COUNT_INTERVAL")
+	return r
+}
+
+// WatchSecrets rotates the JWT secret and the Mongo credentials when the
+// files behind JWT_SECRET_FILE, MONGO_URI_FILE, MONGO_USERNAME_FILE or
+// MONGO_PASSWORD_FILE change, checking every APP_SECRETS_WATCH_INTERVAL.
+func (c *Setting) WatchSecrets(snapshot *Snapshot) {
+	if App.SecretsWatchInterval <= 0 {
+		return
+	}
+	watcher := NewSecretWatcher(snapshot, App.SecretsWatchInterval, c.Logger.Named("secrets"))
+	watcher.Handle("JWT_SECRET", func(ctx context.Context, secret string) error {
+		if secret == "" {
+			return errors.New("JWT_SECRET_FILE is empty")
 		}
+		utils.RotateJWTSecret(secret, utils.JWT.RotationGrace)
+		return nil
+	})
+	for _, key := range []string{"MONGO_URI", "MONGO_USERNAME", "MONGO_PASSWORD"} {
+		key := key
+		watcher.Handle(key, func(ctx context.Context, value string) error {
+			return c.DBMongo.SetCredential(ctx, key, value, store.M.DrainTimeout)
+		})
 	}
+	if watcher.Watching() {
+		c.Go("secret_watcher", watcher.Run)
+	}
+}
 
-	return nil
+// StopApp shuts down in order: fail readiness, give load balancers
+// APP_SHUTDOWN_DELAY to notice, drain in-flight requests within
+// APP_SHUTDOWN_TIMEOUT, stop the workers, then flush traces and close Mongo.
+// ctx is usually the cancelled signal context; every phase gets a fresh one.
+func (c *Setting) StopApp(ctx context.Context) error {
+	manager := shutdown.NewManager(c.Logger)
+
+	manager.Add("not ready", 0, func(context.Context) error {
+		c.Health.SetDraining()
+		return nil
+	})
+	manager.Add("pre-stop delay", 0, shutdown.Delay(App.ShutdownDelay))
+	manager.Add("http", 0, func(context.Context) error {
+		return c.App.ShutdownWithTimeout(App.ShutdownTimeout)
+	})
+	if c.Admin != nil {
+		manager.Add("admin", 0, func(context.Context) error {
+			return c.Admin.ShutdownWithTimeout(App.ShutdownTimeout)
+		})
+	}
+	manager.Add("workers", App.ShutdownTimeout, c.Workers.Stop)
+	if c.stopTracing != nil {
+		manager.Add("tracing", 5*time.Second, c.stopTracing)
+	}
+	if c.DBMongo != nil {
+		manager.Add("mongo", 5*time.Second, c.DBMongo.Disconnect)
+	}
+
+	return manager.Shutdown(ctx)
 }
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var M = new(config)
+
+type config struct {
+	// URI may carry credentials; config print masks its password.
+	URI string `env:"MONGO_URI" json:",omitempty"`
+	DB  string `env:"MONGO_DB" json:",omitempty"`
+
+	// Username and Password, when set, replace the credentials in URI, so the
+	// password can live in its own secret file.
+	Username string `env:"MONGO_USERNAME" json:",omitempty"`
+	Password string `env:"MONGO_PASSWORD" json:"-"`
+
+	// DrainTimeout is how long the old pool may finish its operations after
+	// a credential change before it is closed.
+	DrainTimeout time.Duration `env:"MONGO_DRAIN_TIMEOUT,default=30s" json:",omitempty"`
+}
+
+func (c *config) Validate() error {
+	var errs []error
+	if c.URI == "" {
+		errs = append(errs, errors.New("MONGO_URI must be set"))
+	}
+	if c.DB == "" {
+		errs = append(errs, errors.New("MONGO_DB must be set"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const migrationCollection = "schema_migration"
+
+// Migration is a one-off schema change. Applied versions are recorded in
+// schema_migration so every migration runs once per database.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Migrations must stay ordered by version; only ever append.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "expire one-off auth records with TTL indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{"oauth_code", "oidc_login", "revoked_access_token"} {
+				_, err := db.Collection(name).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0),
+				})
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "expire MFA challenges with a TTL index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("mfa_challenge").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			})
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "index OAuth consent revocations by user and client",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("oauth_consent_revocation").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "clientId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			})
+			return err
+		},
+	},
+}
+
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Migrate applies every migration not yet recorded, in order.
+func Migrate(ctx context.Context, db *mongo.Database) error {
+	pending, err := PendingMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		record := appliedMigration{Version: m.Version, Description: m.Description, AppliedAt: time.Now()}
+		if _, err := db.Collection(migrationCollection).InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("record migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// PendingMigrations lists the migrations not yet applied to db.
+func PendingMigrations(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	cursor, err := db.Collection(migrationCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	var applied []appliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+	var pending []Migration
+	for _, m := range Migrations {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// CheckMigrations is a readiness check that fails while migrations are pending.
+func (s *MongoStore) CheckMigrations(ctx context.Context) error {
+	pending, err := PendingMigrations(ctx, s.Database())
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migration(s) pending, next is %d", len(pending), pending[0].Version)
+	}
+	return nil
+}
@@ -1,28 +1,63 @@
 package store
 
 import (
+	"backend-challenge/pkg/metrics"
 	"context"
 	"fmt"
-	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
+// MongoStore hands out the current client and database. Reconnect swaps
+// them for a new pool, so handlers must ask for Database() per operation
+// instead of keeping one.
 type MongoStore struct {
-	Client *mongo.Client
-	DB     *mongo.Database
+	conn atomic.Pointer[mongoConn]
+
+	mu       sync.Mutex // serializes reconnects
+	settings config
+}
+
+type mongoConn struct {
+	client *mongo.Client
+	db     *mongo.Database
 }
 
 func ConnectMongo(ctx context.Context) (*MongoStore, error) {
-	mongoURI := os.Getenv("MONGO_URI")
-	dbName := os.Getenv("MONGO_DB")
+	s := &MongoStore{settings: *M}
+	conn, err := connect(ctx, s.settings)
+	if err != nil {
+		return nil, err
+	}
+	s.conn.Store(conn)
+	return s, nil
+}
 
+func connect(ctx context.Context, cfg config) (*mongoConn, error) {
 	opts := options.Client().
-		ApplyURI(mongoURI).
+		ApplyURI(cfg.URI).
 		SetMaxConnIdleTime(30 * time.Second).
-		SetServerSelectionTimeout(5 * time.Second)
+		SetServerSelectionTimeout(5 * time.Second).
+		SetMonitor(chainMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor()))
+	if cfg.Username != "" || cfg.Password != "" {
+		// keep authSource and mechanism from the URI, replace only the credentials
+		var credential options.Credential
+		if opts.Auth != nil {
+			credential = *opts.Auth
+		}
+		if cfg.Username != "" {
+			credential.Username = cfg.Username
+		}
+		credential.Password, credential.PasswordSet = cfg.Password, true
+		opts.SetAuth(credential)
+	}
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
@@ -33,11 +68,81 @@ func ConnectMongo(ctx context.Context) (*MongoStore, error) {
 	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 	if err := client.Ping(pingCtx, nil); err != nil {
+		client.Disconnect(context.WithoutCancel(ctx))
 		return nil, fmt.Errorf("mongo ping error: %w", err)
 	}
 
-	return &MongoStore{
-		Client: client,
-		DB:     client.Database(dbName),
-	}, nil
+	return &mongoConn{client: client, db: client.Database(cfg.DB)}, nil
+}
+
+func (s *MongoStore) Client() *mongo.Client {
+	return s.conn.Load().client
+}
+
+func (s *MongoStore) Database() *mongo.Database {
+	return s.conn.Load().db
+}
+
+// SetCredential reconnects with a new MONGO_URI, MONGO_USERNAME or
+// MONGO_PASSWORD. The new pool must answer a ping before it replaces the old
+// one, which then waits for operations in flight (up to drain) before its
+// connections are closed. On error the old pool stays in use.
+func (s *MongoStore) SetCredential(ctx context.Context, key, value string, drain time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := s.settings
+	switch key {
+	case "MONGO_URI":
+		settings.URI = value
+	case "MONGO_USERNAME":
+		settings.Username = value
+	case "MONGO_PASSWORD":
+		settings.Password = value
+	default:
+		return fmt.Errorf("%s is not a mongo credential", key)
+	}
+
+	conn, err := connect(ctx, settings)
+	if err != nil {
+		return err
+	}
+	s.settings = settings
+	old := s.conn.Swap(conn)
+
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drain)
+	defer cancel()
+	return old.client.Disconnect(drainCtx)
+}
+
+// Disconnect closes the current pool.
+func (s *MongoStore) Disconnect(ctx context.Context) error {
+	return s.Client().Disconnect(ctx)
+}
+
+// Ping is a readiness check against the primary.
+func (s *MongoStore) Ping(ctx context.Context) error {
+	return s.Client().Ping(ctx, readpref.Primary())
+}
+
+// chainMonitors lets several command monitors observe the same client; the
+// driver accepts only one.
+func chainMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, e)
+			}
+		},
+	}
 }
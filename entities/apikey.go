@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	Hash       string             `bson:"hash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	CreatedBy  string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:write admin *"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type UpdateAPIKeyRequest struct {
+	Name   string   `json:"name,omitempty" validate:"omitempty"`
+	Scopes []string `json:"scopes,omitempty" validate:"omitempty,min=1,dive,oneof=users:read users:write admin *"`
+}
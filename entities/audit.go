@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditPasswordChanged = "password.changed"
+	AuditPasswordReset   = "password.reset"
+	AuditMFAEnabled      = "mfa.enabled"
+	AuditMFARecoveryUsed = "mfa.recovery_code_used"
+	AuditSessionRevoked  = "session.revoked"
+
+	AuditEmailChanged      = "account.email_changed"
+	AuditDeletionScheduled = "account.deletion_scheduled"
+	AuditDeletionCancelled = "account.deletion_cancelled"
+	AuditAccountDeleted    = "account.deleted"
+
+	AuditAPIKeyCreated = "api_key.created"
+	AuditAPIKeyUpdated = "api_key.updated"
+	AuditAPIKeyRevoked = "api_key.revoked"
+
+	AuditIdentityLinked  = "identity.linked"
+	AuditUserProvisioned = "identity.user_provisioned"
+
+	AuditOAuthClientCreated  = "oauth.client_created"
+	AuditOAuthClientRevoked  = "oauth.client_revoked"
+	AuditOAuthConsentGranted = "oauth.consent_granted"
+	AuditOAuthConsentRevoked = "oauth.consent_revoked"
+	AuditOAuthRefreshReuse   = "oauth.refresh_token_reuse"
+
+	AuditLogLevelChanged = "admin.log_level_changed"
+)
+
+type AuditEvent struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	UserID    string                 `bson:"userId" json:"userId"`
+	Action    string                 `bson:"action" json:"action"`
+	IP        string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string                 `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	RequestID string                 `bson:"requestId,omitempty" json:"requestId,omitempty"`
+	Metadata  map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
+}
@@ -3,7 +3,16 @@ package entities
 type contextKey string
 
 const (
-	LoggerKey = contextKey("logger")
-	RequestId = contextKey("request_id")
-	UserIDKey = contextKey("user_id")
+	LoggerKey    = contextKey("logger")
+	RequestId    = contextKey("request_id")
+	UserIDKey    = contextKey("user_id")
+	SessionIDKey = contextKey("session_id")
+	PrincipalKey = contextKey("principal")
+
+	// QuietSuccessKey tells adapters.Response not to log successful
+	// responses, when only failed or slow requests are logged.
+	QuietSuccessKey = contextKey("quiet_success")
 )
+
+// RequestIDHeader carries the correlation id on responses and outbound calls.
+const RequestIDHeader = "X-Request-ID"
@@ -0,0 +1,10 @@
+package entities
+
+// SetLogLevelRequest changes the level of one logger name, or the default
+// level when Logger is empty. TTL is a duration such as "15m" after which the
+// change reverts.
+type SetLogLevelRequest struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level" validate:"required"`
+	TTL    string `json:"ttl,omitempty"`
+}
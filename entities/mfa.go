@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	QRCodePNG  string `json:"qrCodePng"` // base64 encoded
+}
+
+type MFAConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP code or a recovery code
+}
+
+// MFAChallenge tracks the token returned by Login so it can be exchanged once
+// and only guessed a limited number of times.
+type MFAChallenge struct {
+	ID        string     `bson:"_id" json:"id"`
+	UserID    string     `bson:"userId" json:"userId"`
+	Attempts  int        `bson:"attempts" json:"attempts"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `bson:"usedAt" json:"usedAt,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,111 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuthClient is an application registered to use this service as its
+// authorization server. Public clients have no secret and must use PKCE.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClientID     string             `bson:"clientId" json:"clientId"`
+	SecretHash   string             `bson:"secretHash,omitempty" json:"-"`
+	Name         string             `bson:"name" json:"name"`
+	RedirectURIs []string           `bson:"redirectUris" json:"redirectUris"`
+	GrantTypes   []string           `bson:"grantTypes" json:"grantTypes"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	Public       bool               `bson:"public" json:"public"`
+	CreatedBy    string             `bson:"createdBy" json:"createdBy"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	RevokedAt    *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+func (c OAuthClient) Active() bool {
+	return c.RevokedAt == nil
+}
+
+func (c OAuthClient) AllowsGrant(grant string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grant {
+			return true
+		}
+	}
+	return false
+}
+
+func (c OAuthClient) AllowsRedirect(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthCode is an authorization code. Only the hash of the code is stored.
+type OAuthCode struct {
+	ID            string    `bson:"_id"` // hash of the code
+	ClientID      string    `bson:"clientId"`
+	UserID        string    `bson:"userId"`
+	RedirectURI   string    `bson:"redirectUri"`
+	Scopes        []string  `bson:"scopes"`
+	CodeChallenge string    `bson:"codeChallenge"`
+	ExpiresAt     time.Time `bson:"expiresAt"`
+	CreatedAt     time.Time `bson:"createdAt"`
+}
+
+// OAuthRefreshToken is rotated on every use. All tokens descending from the
+// same authorization share a Family, so reuse of a rotated token revokes the
+// whole family.
+type OAuthRefreshToken struct {
+	ID        string     `bson:"_id"` // hash of the token
+	Family    string     `bson:"family"`
+	ClientID  string     `bson:"clientId"`
+	UserID    string     `bson:"userId"`
+	Scopes    []string   `bson:"scopes"`
+	ExpiresAt time.Time  `bson:"expiresAt"`
+	CreatedAt time.Time  `bson:"createdAt"`
+	RevokedAt *time.Time `bson:"revokedAt,omitempty"`
+}
+
+func (t OAuthRefreshToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// OAuthConsent records the scopes a user has granted to a client.
+type OAuthConsent struct {
+	UserID    string    `bson:"userId" json:"userId"`
+	ClientID  string    `bson:"clientId" json:"clientId"`
+	Scopes    []string  `bson:"scopes" json:"scopes"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CreateOAuthClientRequest has no admin scope: that stays with API keys and
+// admin users.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirectUris" validate:"omitempty,dive,url"`
+	GrantTypes   []string `json:"grantTypes" validate:"required,min=1,dive,oneof=authorization_code client_credentials refresh_token"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:write"`
+	Public       bool     `json:"public"`
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" validate:"required,eq=code"`
+	ClientID            string `json:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri" validate:"required"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge" validate:"required,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" validate:"required,eq=S256"`
+	Approve             bool   `json:"approve"`
+}
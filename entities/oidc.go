@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+// OIDCLogin keeps the state of an authorization code + PKCE login between the
+// redirect to the identity provider and the callback.
+type OIDCLogin struct {
+	State        string    `bson:"_id"`
+	Nonce        string    `bson:"nonce"`
+	CodeVerifier string    `bson:"codeVerifier"`
+	ExpiresAt    time.Time `bson:"expiresAt"`
+	CreatedAt    time.Time `bson:"createdAt"`
+}
@@ -0,0 +1,25 @@
+package entities
+
+import "time"
+
+type PasswordReset struct {
+	ID        string     `bson:"_id" json:"-"` // hash of the token, the plain token is only mailed
+	UserID    string     `bson:"userId" json:"userId"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `bson:"usedAt" json:"usedAt,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,nefield=CurrentPassword"`
+}
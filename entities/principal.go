@@ -0,0 +1,51 @@
+package entities
+
+import "context"
+
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeAdmin      = "admin"
+	ScopeAll        = "*"
+)
+
+// Principal is the authenticated caller: a user logged in with a JWT or a
+// service using an API key, an OAuth client token or a client certificate.
+type Principal struct {
+	Type   string   `json:"type"`
+	ID     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is the OAuth client a user token was issued to, empty for the
+	// user's own logins.
+	ClientID string `json:"clientId,omitempty"`
+}
+
+// FirstParty reports whether a user principal comes from the user's own
+// login rather than a token delegated to an OAuth client.
+func (p Principal) FirstParty() bool {
+	return p.Type == PrincipalUser && p.ClientID == ""
+}
+
+// HasScope reports whether the principal may use scope. First-party user
+// tokens carry no scopes and may use everything except "admin", which is
+// decided by the user's role instead.
+func (p Principal) HasScope(scope string) bool {
+	if p.Type == PrincipalUser && len(p.Scopes) == 0 {
+		return scope != ScopeAdmin
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(PrincipalKey).(Principal)
+	return p, ok
+}
@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"userId" json:"userId"`
+	TokenID    string             `bson:"tokenId" json:"-"` // jti of the access token
+	UserAgent  string             `bson:"userAgent" json:"userAgent"`
+	IP         string             `bson:"ip" json:"ip"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastSeenAt time.Time          `bson:"lastSeenAt" json:"lastSeenAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt  *time.Time         `bson:"revokedAt" json:"revokedAt,omitempty"`
+	Current    bool               `bson:"-" json:"current"`
+}
+
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
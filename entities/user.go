@@ -6,12 +6,79 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name      string             `bson:"name" json:"name" validate:"required"`
 	Email     string             `bson:"email" json:"email" validate:"required,email"`
 	Password  string             `bson:"password" json:"password" validate:"required"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	Role      string             `bson:"role,omitempty" json:"role,omitempty"`
+
+	EmailVerified   bool       `bson:"emailVerified" json:"emailVerified"`
+	EmailVerifiedAt *time.Time `bson:"emailVerifiedAt,omitempty" json:"emailVerifiedAt,omitempty"`
+
+	PasswordChangedAt *time.Time `bson:"passwordChangedAt,omitempty" json:"passwordChangedAt,omitempty"`
+	TokensRevokedAt   *time.Time `bson:"tokensRevokedAt,omitempty" json:"-"`
+
+	MFA *MFASettings `bson:"mfa,omitempty" json:"-"`
+
+	DeletionScheduledAt *time.Time `bson:"deletionScheduledAt,omitempty" json:"deletionScheduledAt,omitempty"`
+
+	Identities []ExternalIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
+}
+
+// UserResponse is a user as the API returns it, without the password hash.
+type UserResponse struct {
+	ID                  primitive.ObjectID `json:"id"`
+	Name                string             `json:"name"`
+	Email               string             `json:"email"`
+	CreatedAt           time.Time          `json:"createdAt"`
+	Role                string             `json:"role,omitempty"`
+	EmailVerified       bool               `json:"emailVerified"`
+	EmailVerifiedAt     *time.Time         `json:"emailVerifiedAt,omitempty"`
+	PasswordChangedAt   *time.Time         `json:"passwordChangedAt,omitempty"`
+	DeletionScheduledAt *time.Time         `json:"deletionScheduledAt,omitempty"`
+	Identities          []ExternalIdentity `json:"identities,omitempty"`
+}
+
+func NewUserResponse(user User) UserResponse {
+	return UserResponse{
+		ID:                  user.ID,
+		Name:                user.Name,
+		Email:               user.Email,
+		CreatedAt:           user.CreatedAt,
+		Role:                user.Role,
+		EmailVerified:       user.EmailVerified,
+		EmailVerifiedAt:     user.EmailVerifiedAt,
+		PasswordChangedAt:   user.PasswordChangedAt,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		Identities:          user.Identities,
+	}
+}
+
+// ExternalIdentity links the user to an account at an external identity provider.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	LinkedAt time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+type MFASettings struct {
+	Enabled       bool       `bson:"enabled"`
+	Secret        string     `bson:"secret,omitempty"`
+	PendingSecret string     `bson:"pendingSecret,omitempty"`
+	RecoveryCodes []string   `bson:"recoveryCodes,omitempty"` // hashed
+	LastUsedStep  int64      `bson:"lastUsedStep,omitempty"`
+	EnabledAt     *time.Time `bson:"enabledAt,omitempty"`
+}
+
+func (u User) MFAEnabled() bool {
+	return u.MFA != nil && u.MFA.Enabled
 }
 
 type Login struct {
@@ -23,3 +90,9 @@ type UpdateUserRequest struct {
 	Name  string `json:"name,omitempty" validate:"omitempty"`
 	Email string `json:"email,omitempty" validate:"omitempty,email"`
 }
+
+type UpdateMeRequest struct {
+	Name            string `json:"name,omitempty" validate:"omitempty"`
+	Email           string `json:"email,omitempty" validate:"omitempty,email"`
+	CurrentPassword string `json:"currentPassword,omitempty"` // required when the email changes
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+type EmailVerification struct {
+	ID        string     `bson:"_id" json:"id"`
+	UserID    string     `bson:"userId" json:"userId"`
+	Email     string     `bson:"email" json:"email"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `bson:"usedAt" json:"usedAt,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -6,6 +6,8 @@ import (
 	"backend-challenge/routers"
 	"backend-challenge/utils"
 	"context"
+	"fmt"
+	"os"
 	"os/signal"
 	"syscall"
 
@@ -15,11 +17,32 @@ import (
 func main() {
 	godotenv.Load()
 
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		os.Exit(printConfig(args[2:]))
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	logger := logging.NewLoggerFromEnv()
-	ctx = logging.WithLogger(ctx, logger)
 	defer stop()
 
+	// config ต้องโหลดก่อน logger เพราะ path, size, timezone ของไฟล์ log มาจาก config
+	snapshot, err := configs.Load(ctx, args)
+	if err != nil {
+		if configs.IsHelp(err) {
+			configs.Usage(os.Stdout)
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	if err != nil {
+		logging.DefaultLogger().Fatal(err)
+	}
+	defer closeLogs()
+	logging.SetDefault(logger)
+	ctx = logging.WithLogger(ctx, logger)
+
 	// app
 	app := configs.NewApp(logger)
 	if err := app.SetApp(ctx); err != nil {
@@ -28,9 +51,24 @@ func main() {
 
 	routers.SetupRoutes(app)
 	errChan := app.RunApp(ctx)
+	app.WatchSecrets(snapshot)
+
+	// SIGHUP อ่าน config ใหม่ ค่าที่เปลี่ยนได้ตอนรันจะมีผลทันที ค่าอื่นต้อง restart
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	reloader := app.Reloader(configs.Loader{Args: args}, snapshot)
+	app.Go("config_reloader", func(ctx context.Context) {
+		reloader.Run(ctx, hup)
+	})
 
 	// task background process
-	utils.StartUserCountLogger(ctx, app.DBMongo.DB, logger)
+	app.Go("user_count_logger", func(ctx context.Context) {
+		utils.RunUserCountLogger(ctx, app.DBMongo, logger, app.UserCountInterval)
+	})
+	app.Go("account_purger", func(ctx context.Context) {
+		utils.RunAccountPurger(ctx, app.DBMongo, logger, configs.Auth.PurgeInterval)
+	})
 
 	select {
 	case <-ctx.Done():
@@ -45,3 +83,24 @@ func main() {
 		logger.Info("shutdown complete")
 	}
 }
+
+// printConfig is "config print [flags]": the effective config as YAML, with
+// secrets redacted, followed by any problems.
+func printConfig(args []string) int {
+	snapshot, err := configs.Load(context.Background(), args)
+	if configs.IsHelp(err) {
+		configs.Usage(os.Stdout)
+		return 0
+	}
+	if snapshot != nil {
+		if perr := snapshot.Print(os.Stdout); perr != nil {
+			fmt.Fprintln(os.Stderr, perr)
+			return 1
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
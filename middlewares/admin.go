@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware guards the admin listener. With a token, requests must send
+// it as a bearer token; without one the listener relies on its address being
+// private. Callers run as the "admin" service principal, so audit records
+// show where a change came from.
+func AdminMiddleware(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token != "" {
+			sent := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid admin token", ErrorCode: "ER401", StatusCode: 401})
+			}
+		}
+		principal := entities.Principal{Type: entities.PrincipalService, ID: "admin", Name: "admin listener", Scopes: []string{entities.ScopeAdmin}}
+		c.SetUserContext(context.WithValue(c.UserContext(), entities.PrincipalKey, principal))
+		return c.Next()
+	}
+}
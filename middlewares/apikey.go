@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/utils"
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+type apiKeyStore interface {
+	GetAPIKeyByPrefix(prefix string, ctx context.Context) (entities.APIKey, error)
+	TouchAPIKey(keyId string, interval time.Duration, ctx context.Context) error
+}
+
+func authenticateAPIKey(c *fiber.Ctx, cfg JWTConfig, apiKey string) error {
+	logger := logging.FromContext(c.UserContext())
+
+	prefix, ok := utils.ParseAPIKey(apiKey)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid API key", ErrorCode: "ER401", StatusCode: 401})
+	}
+
+	key, err := cfg.APIKeys.GetAPIKeyByPrefix(prefix, c.UserContext())
+	if err != nil || !utils.HashEqual(key.Hash, utils.Hash(apiKey)) || !key.Active(time.Now()) {
+		logger.Warnw("API key rejected", "prefix", prefix)
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid API key", ErrorCode: "ER401", StatusCode: 401})
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > cfg.SessionTouchInterval {
+		if err := cfg.APIKeys.TouchAPIKey(key.ID.Hex(), cfg.SessionTouchInterval, c.UserContext()); err != nil {
+			logger.Warnw("failed to update API key last used", "error", err)
+		}
+	}
+
+	principal := entities.Principal{
+		Type:   entities.PrincipalService,
+		ID:     key.ID.Hex(),
+		Name:   key.Name,
+		Scopes: key.Scopes,
+	}
+	c.SetUserContext(context.WithValue(c.UserContext(), entities.PrincipalKey, principal))
+
+	return c.Next()
+}
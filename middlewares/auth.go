@@ -3,30 +3,159 @@ package middlewares
 import (
 	handlers "backend-challenge/adapters/http"
 	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
 	"backend-challenge/utils"
 	"context"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func JWTMiddleware() fiber.Handler {
+type tokenRevocationChecker interface {
+	TokensRevokedAt(userId string, ctx context.Context) (time.Time, error)
+}
+
+type sessionChecker interface {
+	GetSession(sessionId string, ctx context.Context) (entities.Session, error)
+	TouchSession(sessionId string, interval time.Duration, ctx context.Context) error
+}
+
+type accessTokenDenylist interface {
+	IsAccessTokenRevoked(tokenId string, ctx context.Context) (bool, error)
+	OAuthTokensRevokedAt(clientId string, userId string, ctx context.Context) (time.Time, error)
+}
+
+type JWTConfig struct {
+	// Revocations rejects tokens issued before the user's last revocation,
+	// e.g. a password change. Optional.
+	Revocations tokenRevocationChecker
+	// Sessions rejects tokens whose session was revoked. Optional.
+	Sessions sessionChecker
+	// SessionTouchInterval throttles lastSeenAt updates. Default 1 minute.
+	SessionTouchInterval time.Duration
+	// APIKeys enables the X-API-Key header for service callers. Optional.
+	APIKeys apiKeyStore
+	// AccessTokens rejects OAuth access tokens revoked through /oauth/revoke,
+	// or issued before their client or the user's consent was revoked.
+	// Optional.
+	AccessTokens accessTokenDenylist
+	// Cookie accepts the access token from a cookie when no Authorization
+	// header is sent. Optional.
+	Cookie *CookieAuth
+	// ClientCerts accepts a verified mTLS client certificate when no token
+	// or API key is sent. Optional.
+	ClientCerts *ClientCertAuth
+}
+
+func JWTMiddleware(config ...JWTConfig) fiber.Handler {
+	var cfg JWTConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.SessionTouchInterval <= 0 {
+		cfg.SessionTouchInterval = time.Minute
+	}
+
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get(HeaderAPIKey); apiKey != "" && cfg.APIKeys != nil {
+			return authenticateAPIKey(c, cfg, apiKey)
+		}
+
+		var tokenStr string
 		authHeader := c.Get("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") {
+		switch {
+		case strings.HasPrefix(authHeader, "Bearer "):
+			tokenStr = strings.TrimPrefix(authHeader, "Bearer ")
+		case cfg.Cookie != nil && c.Cookies(cfg.Cookie.Name) != "":
+			tokenStr = c.Cookies(cfg.Cookie.Name)
+			if !validCSRF(c, cfg.Cookie) {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Missing or invalid CSRF token", ErrorCode: "ER403", StatusCode: 403})
+			}
+		default:
+			if cfg.ClientCerts != nil {
+				if principal, ok := clientCertPrincipal(c, cfg.ClientCerts); ok {
+					return authenticateClientCert(c, principal)
+				}
+			}
 			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Missing or invalid token", ErrorCode: "ER401", StatusCode: 401})
 		}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := utils.ParseToken(tokenStr)
 		if err != nil {
-			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: " + err.Error(), ErrorCode: "ER401", StatusCode: 401})
+			// the reason is logged only, clients get a generic message
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid token", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "JWTMiddleware", "reason": err.Error()})
+		}
+
+		userID, clientID := claims.UserID, claims.ClientID
+		if clientID != "" && cfg.AccessTokens != nil {
+			revoked, err := oauthTokenRevoked(cfg.AccessTokens, claims, c.UserContext())
+			if err != nil {
+				logging.FromContext(c.UserContext()).Warnw("access token revocation check failed", "error", err)
+			}
+			if err != nil || revoked {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: token has been revoked", ErrorCode: "ER401", StatusCode: 401})
+			}
+		}
+
+		if userID == "" {
+			if clientID == "" {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid token", ErrorCode: "ER401", StatusCode: 401})
+			}
+			// client_credentials token: the client itself is the caller
+			principal := entities.Principal{Type: entities.PrincipalService, ID: clientID, Name: clientID, Scopes: strings.Fields(claims.Scope)}
+			c.SetUserContext(context.WithValue(c.UserContext(), entities.PrincipalKey, principal))
+			return c.Next()
+		}
+
+		if cfg.Revocations != nil {
+			issuedAt := claims.IssuedAt
+			revokedAt, err := cfg.Revocations.TokensRevokedAt(userID, c.UserContext())
+			if err != nil {
+				logging.FromContext(c.UserContext()).Warnw("token revocation check failed", "error", err)
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: invalid token", ErrorCode: "ER401", StatusCode: 401})
+			}
+			// iat has second precision, so a token from the same second as the revocation is rejected too
+			if issuedAt == nil || (!revokedAt.IsZero() && issuedAt.Unix() <= revokedAt.Unix()) {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: token has been revoked", ErrorCode: "ER401", StatusCode: 401})
+			}
 		}
 
-		userID := claims["user_id"]
 		ctx := context.WithValue(c.UserContext(), entities.UserIDKey, userID)
+		// OAuth tokens are tied to their refresh token rather than a login session
+		if cfg.Sessions != nil && clientID == "" {
+			sessionID := claims.SessionID
+			session, err := cfg.Sessions.GetSession(sessionID, c.UserContext())
+			if err != nil || session.UserID != userID || !session.Active(time.Now()) {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized: session has been revoked", ErrorCode: "ER401", StatusCode: 401})
+			}
+
+			if time.Since(session.LastSeenAt) > cfg.SessionTouchInterval {
+				if err := cfg.Sessions.TouchSession(sessionID, cfg.SessionTouchInterval, c.UserContext()); err != nil {
+					logging.FromContext(c.UserContext()).Warnw("failed to update session last seen", "error", err)
+				}
+			}
+			ctx = context.WithValue(ctx, entities.SessionIDKey, sessionID)
+		}
+
+		principal := entities.Principal{Type: entities.PrincipalUser, ID: userID, Scopes: strings.Fields(claims.Scope), ClientID: clientID}
+		ctx = context.WithValue(ctx, entities.PrincipalKey, principal)
 		c.SetUserContext(ctx)
 
 		return c.Next()
 	}
 }
+
+// oauthTokenRevoked checks the token's own revocation and whether it was
+// issued before its client or the user's consent was revoked.
+func oauthTokenRevoked(denylist accessTokenDenylist, claims *utils.Claims, ctx context.Context) (bool, error) {
+	if revoked, err := denylist.IsAccessTokenRevoked(claims.ID, ctx); err != nil || revoked {
+		return true, err
+	}
+	revokedAt, err := denylist.OAuthTokensRevokedAt(claims.ClientID, claims.UserID, ctx)
+	if err != nil {
+		return true, err
+	}
+	// iat has second precision, as for TokensRevokedAt
+	return claims.IssuedAt == nil || (!revokedAt.IsZero() && claims.IssuedAt.Unix() <= revokedAt.Unix()), nil
+}
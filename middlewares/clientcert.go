@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"backend-challenge/entities"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientCertAuth lets services authenticate with the client certificate of
+// an mTLS connection instead of a token. Only certificates verified during
+// the handshake count.
+type ClientCertAuth struct {
+	// Scopes by certificate common name. Certificates not listed get a
+	// principal without scopes.
+	Scopes map[string][]string
+}
+
+func clientCertPrincipal(c *fiber.Ctx, cfg *ClientCertAuth) (entities.Principal, bool) {
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return entities.Principal{}, false
+	}
+	subject := state.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return entities.Principal{}, false
+	}
+	return entities.Principal{
+		Type:   entities.PrincipalService,
+		ID:     subject.CommonName,
+		Name:   subject.String(),
+		Scopes: cfg.Scopes[subject.CommonName],
+	}, true
+}
+
+func authenticateClientCert(c *fiber.Ctx, principal entities.Principal) error {
+	c.SetUserContext(context.WithValue(c.UserContext(), entities.PrincipalKey, principal))
+	return c.Next()
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CookieAuth lets browser clients authenticate with the access token cookie
+// set by /auth/login. Requests authenticated this way must echo the CSRF
+// cookie in a header on unsafe methods (double-submit).
+type CookieAuth struct {
+	Name       string
+	CSRFCookie string
+	CSRFHeader string
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// validCSRF reports whether the request may proceed under cookie auth.
+func validCSRF(c *fiber.Ctx, cfg *CookieAuth) bool {
+	if isSafeMethod(c.Method()) {
+		return true
+	}
+	cookie, header := c.Cookies(cfg.CSRFCookie), c.Get(cfg.CSRFHeader)
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
@@ -7,17 +7,25 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
-func LoggerMiddleware(logger *zap.SugaredLogger) fiber.Handler {
+// LoggerMiddleware logs one line per request that filter keeps.
+func LoggerMiddleware(logger *zap.SugaredLogger, filter logging.RequestFilter) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		requestID := uuid.New().String()
 		ctx := c.UserContext()
+		requestID, _ := ctx.Value(entities.RequestId).(string)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
 		logger := logger.With("request_id", requestID)
 		ctx = logging.WithLogger(ctx, logger)
 		ctx = context.WithValue(ctx, entities.RequestId, requestID)
+		if filter.OnlyErrors {
+			ctx = logging.WithQuietSuccess(ctx)
+		}
 		c.SetUserContext(ctx)
 
 		start := time.Now()
@@ -25,13 +33,27 @@ func LoggerMiddleware(logger *zap.SugaredLogger) fiber.Handler {
 		stop := time.Now()
 		latency := stop.Sub(start)
 
-		logger.Infow("HTTP Request",
-			"method", c.Method(),
-			"path", c.OriginalURL(),
-			"status", c.Response().StatusCode(),
+		status := c.Response().StatusCode()
+		if err != nil {
+			// the error handler has not written the response yet
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+		if !filter.Keep(status, latency) {
+			return err
+		}
+
+		// fiber reuses its buffers after the handler returns, and sinks may
+		// hold the fields longer than that
+		logging.FromContext(ctx).Infow("HTTP Request",
+			"method", utils.CopyString(c.Method()),
+			"path", utils.CopyString(c.OriginalURL()),
+			"status", status,
 			"latency", latency.String(),
-			"ip", c.IP(),
-			"user_agent", c.Get("User-Agent"),
+			"ip", utils.CopyString(c.IP()),
+			"user_agent", utils.CopyString(c.Get("User-Agent")),
 		)
 
 		return err
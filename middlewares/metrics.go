@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"backend-challenge/pkg/metrics"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware counts requests and observes their latency by route
+// template, so /users/:id is one series no matter the id.
+func MetricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		labels := []string{c.Route().Path, c.Method(), strconv.Itoa(responseStatus(c, err))}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// responseStatus is the status the client will get, including errors that
+// are only turned into a response by the app's error handler.
+func responseStatus(c *fiber.Ctx, err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	} else if err != nil {
+		return fiber.StatusInternalServerError
+	}
+	return c.Response().StatusCode()
+}
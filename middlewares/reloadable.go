@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reloadable is a middleware slot whose handler can be replaced while the app
+// serves requests, e.g. CORS after a config reload. Requests already inside
+// the old handler finish with it.
+type Reloadable struct {
+	handler atomic.Pointer[fiber.Handler]
+}
+
+func NewReloadable(handler fiber.Handler) *Reloadable {
+	r := new(Reloadable)
+	r.Store(handler)
+	return r
+}
+
+func (r *Reloadable) Store(handler fiber.Handler) {
+	r.handler.Store(&handler)
+}
+
+func (r *Reloadable) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return (*r.handler.Load())(c)
+	}
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// validRequestID accepts the usual id shapes (UUIDs, ULIDs, gateway ids) and
+// nothing that could break a log line or a header.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware takes the correlation id from the inbound header when it
+// is well formed, otherwise mints one, and echoes it in X-Request-ID. The
+// response header is set before the rest of the chain runs so it survives
+// panics caught by recover and 404s from the router. Register it first.
+func RequestIDMiddleware(header string) fiber.Handler {
+	if header == "" {
+		header = entities.RequestIDHeader
+	}
+	return func(c *fiber.Ctx) error {
+		// copied, the header bytes belong to fasthttp and are reused after the
+		// request while the context may be kept by goroutines and log lines
+		requestID := utils.CopyString(c.Get(header))
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set(entities.RequestIDHeader, requestID)
+		c.SetUserContext(context.WithValue(c.UserContext(), entities.RequestId, requestID))
+
+		return c.Next()
+	}
+}
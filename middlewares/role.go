@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type roleLookup interface {
+	GetUserRole(userId string, ctx context.Context) (string, error)
+}
+
+// RequireRole must run after JWTMiddleware. A user's role is read from the
+// store on every request, so a demotion takes effect without waiting for the
+// token to expire. Service principals pass when they hold the role as a scope.
+func RequireRole(users roleLookup, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := entities.PrincipalFromContext(c.UserContext())
+		if !ok {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+
+		if principal.Type == entities.PrincipalService {
+			for _, r := range roles {
+				if principal.HasScope(r) {
+					return c.Next()
+				}
+			}
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+
+		if !hasRole(c, users, principal.ID, roles) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+		return c.Next()
+	}
+}
+
+// RequireUserRole must run after JWTMiddleware. Users need one of roles, read
+// like RequireRole; service principals pass and are left to RequireScope, so
+// a users:write API key keeps working while a plain user cannot act on other
+// accounts.
+func RequireUserRole(users roleLookup, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := entities.PrincipalFromContext(c.UserContext())
+		if !ok {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+		if principal.Type != entities.PrincipalService && !hasRole(c, users, principal.ID, roles) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+		return c.Next()
+	}
+}
+
+func hasRole(c *fiber.Ctx, users roleLookup, userId string, roles []string) bool {
+	role, err := users.GetUserRole(userId, c.UserContext())
+	if err != nil {
+		logging.FromContext(c.UserContext()).Warnw("role lookup failed", "error", err)
+		return false
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope must run after JWTMiddleware and passes when the principal
+// holds every listed scope.
+func RequireScope(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := entities.PrincipalFromContext(c.UserContext())
+		if !ok {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden", ErrorCode: "ER403", StatusCode: 403})
+		}
+
+		for _, s := range scopes {
+			if !principal.HasScope(s) {
+				return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden: missing scope " + s, ErrorCode: "ER403", StatusCode: 403})
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireFirstParty must run after JWTMiddleware and passes only the user's
+// own logins. Account self-service and OAuth consent are not for tokens a
+// user delegated to an OAuth client, whatever their scopes.
+func RequireFirstParty() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := entities.PrincipalFromContext(c.UserContext())
+		if !ok || !principal.FirstParty() {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Forbidden: not available to OAuth clients", ErrorCode: "ER403", StatusCode: 403})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"backend-challenge/pkg/tracing"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestCarrier exposes the fasthttp request headers to the propagator.
+type requestCarrier struct {
+	c *fiber.Ctx
+}
+
+func (r requestCarrier) Get(key string) string {
+	return r.c.Get(key)
+}
+
+func (r requestCarrier) Set(key, value string) {
+	r.c.Request().Header.Set(key, value)
+}
+
+func (r requestCarrier) Keys() []string {
+	var keys []string
+	r.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// TracingMiddleware starts a server span per request, continuing the trace of
+// an incoming traceparent header. It must run before LoggerMiddleware so the
+// request logger sees the span.
+func TracingMiddleware() fiber.Handler {
+	tracer := tracing.Tracer()
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestCarrier{c})
+		ctx, span := tracer.Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(c.Method()), semconv.URLPath(c.Path())),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+
+		return err
+	}
+}
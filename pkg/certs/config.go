@@ -0,0 +1,176 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var C = new(config)
+
+const (
+	ClientAuthRequire  = "require"
+	ClientAuthOptional = "optional"
+)
+
+type config struct {
+	// CertFile and KeyFile turn on TLS for the main listener.
+	CertFile       string        `env:"TLS_CERT_FILE" json:",omitempty"`
+	KeyFile        string        `env:"TLS_KEY_FILE" json:",omitempty"`
+	ReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL,default=30s" json:",omitempty"`
+	MinVersion     string        `env:"TLS_MIN_VERSION,default=1.2" json:",omitempty"`
+	// CipherSuites limits TLS 1.2 and older; TLS 1.3 suites are not
+	// configurable. Empty keeps the Go defaults.
+	CipherSuites []string `env:"TLS_CIPHER_SUITES" json:",omitempty"`
+
+	// ClientCAFile turns on mTLS: client certificates must chain to one of
+	// its CAs. ClientAuth optional also lets clients without a certificate in.
+	ClientCAFile string       `env:"TLS_CLIENT_CA_FILE" json:",omitempty"`
+	ClientAuth   string       `env:"TLS_CLIENT_AUTH,default=require" json:",omitempty"`
+	ClientScopes ClientScopes `env:"TLS_CLIENT_SCOPES" json:",omitempty"`
+}
+
+func (c *config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c *config) Validate() error {
+	var errs []error
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.ReloadInterval < 0 {
+		errs = append(errs, fmt.Errorf("TLS_RELOAD_INTERVAL must not be negative"))
+	}
+	if _, err := parseVersion(c.MinVersion); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := parseCipherSuites(c.CipherSuites); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.ClientAuth {
+	case "", ClientAuthRequire, ClientAuthOptional:
+	default:
+		errs = append(errs, fmt.Errorf("TLS_CLIENT_AUTH %q is not require or optional", c.ClientAuth))
+	}
+	if c.ClientCAFile != "" && !c.Enabled() {
+		errs = append(errs, fmt.Errorf("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+	return errors.Join(errs...)
+}
+
+// ServerConfig builds the listener config. The certificate always comes from
+// certs, so a reload is picked up by the next handshake.
+func (c *config) ServerConfig(certs *Reloader) (*tls.Config, error) {
+	version, err := parseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := parseCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     version,
+		CipherSuites:   suites,
+		GetCertificate: certs.GetCertificate,
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE %s has no PEM certificates", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.ClientAuth == ClientAuthOptional {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return cfg, nil
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(version, "TLS") {
+	case "1.0", "1":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("TLS_MIN_VERSION %q is not 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// parseCipherSuites accepts the names used by crypto/tls, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure suites are refused.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("TLS_CIPHER_SUITES: unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ClientScopes maps the common name of a client certificate to the scopes of
+// its service principal, e.g. "billing=users:read users:write,reports=users:read".
+// In a config file it is a map of names to scope lists.
+type ClientScopes map[string][]string
+
+func (s *ClientScopes) EnvDecode(value string) error {
+	scopes := ClientScopes{}
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &raw); err != nil {
+			return fmt.Errorf("TLS_CLIENT_SCOPES: %w", err)
+		}
+		for name, v := range raw {
+			switch v := v.(type) {
+			case string:
+				scopes[name] = strings.Fields(v)
+			case []interface{}:
+				for _, scope := range v {
+					scopes[name] = append(scopes[name], fmt.Sprint(scope))
+				}
+			default:
+				return fmt.Errorf("TLS_CLIENT_SCOPES %q must list scopes", name)
+			}
+		}
+		*s = scopes
+		return nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("TLS_CLIENT_SCOPES %q must look like name=scope scope", part)
+		}
+		scopes[strings.TrimSpace(name)] = strings.Fields(list)
+	}
+	*s = scopes
+	return nil
+}
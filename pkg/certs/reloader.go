@@ -0,0 +1,111 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reloader serves the certificate in CertFile/KeyFile and picks up new
+// versions of the files, e.g. after cert-manager renews them. A pair that
+// does not load, such as a half-written renewal, keeps the current
+// certificate until the next check.
+type Reloader struct {
+	certFile, keyFile string
+	logger            *zap.SugaredLogger
+
+	cert atomic.Pointer[tls.Certificate]
+
+	mu              sync.Mutex
+	certPEM, keyPEM []byte
+}
+
+// NewReloader loads the pair once; it fails when the files do not hold a
+// valid certificate and matching key.
+func NewReloader(certFile, keyFile string, logger *zap.SugaredLogger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if _, err := r.Check(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Certificate is the leaf certificate currently served.
+func (r *Reloader) Certificate() *x509.Certificate {
+	return r.cert.Load().Leaf
+}
+
+// Check reads the files and swaps in the pair when it changed. It reports
+// whether a new certificate was loaded.
+func (r *Reloader) Check() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("TLS_CERT_FILE: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("TLS_KEY_FILE: %w", err)
+	}
+	if bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) {
+		return false, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("TLS certificate %s: %w", r.certFile, err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false, fmt.Errorf("TLS certificate %s: %w", r.certFile, err)
+		}
+	}
+	r.cert.Store(&cert)
+	r.certPEM, r.keyPEM = certPEM, keyPEM
+	return true, nil
+}
+
+// Run checks the files every interval until ctx is done. interval must be
+// positive.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Check()
+			if err != nil {
+				r.logger.Errorw("TLS certificate reload failed, keeping the current one", "error", err)
+				continue
+			}
+			if changed {
+				leaf := r.Certificate()
+				r.logger.Infow("TLS certificate reloaded", "subject", leaf.Subject.String(), "not_after", leaf.NotAfter)
+			}
+		}
+	}
+}
+
+// Expiry is a health check that fails once the served certificate expired.
+func (r *Reloader) Expiry(ctx context.Context) error {
+	if leaf := r.Certificate(); time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("TLS certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check reports an unhealthy dependency by returning an error. It must
+// honor ctx, which carries the registry timeout.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status   string        `json:"status"`
+	Draining bool          `json:"draining,omitempty"`
+	Checks   []CheckResult `json:"checks"`
+	Config   *ConfigStatus `json:"config,omitempty"`
+}
+
+// ConfigStatus tells which configuration the instance runs and how the last
+// reload went.
+type ConfigStatus struct {
+	Version    int           `json:"version"`
+	LoadedAt   time.Time     `json:"loadedAt"`
+	LastReload *ReloadResult `json:"lastReload,omitempty"`
+}
+
+// WorkerStatus is one background loop as listed by the admin listener.
+type WorkerStatus struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	StoppedAt *time.Time `json:"stoppedAt,omitempty"`
+}
+
+type ReloadResult struct {
+	At      time.Time `json:"at"`
+	Status  string    `json:"status"` // applied, unchanged, rejected or failed
+	Changed []string  `json:"changed,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Ready reports whether the instance should receive traffic.
+func (r Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+// Registry is where subsystems register their readiness checks. It also
+// remembers whether shutdown has started, so readiness fails while the
+// load balancer drains the instance.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	timeout  time.Duration
+	draining atomic.Bool
+	config   *ConfigStatus
+	workers  map[string]*Worker
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{checks: map[string]Check{}, timeout: timeout, workers: map[string]*Worker{}}
+}
+
+// Register adds or replaces the check called name.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Worker registers a check that fails unless the returned worker is running.
+func (r *Registry) Worker(name string) *Worker {
+	worker := &Worker{name: name}
+	r.Register("worker:"+name, worker.Check)
+	r.mu.Lock()
+	r.workers[name] = worker
+	r.mu.Unlock()
+	return worker
+}
+
+// Workers lists the registered workers by name.
+func (r *Registry) Workers() []WorkerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := make([]WorkerStatus, 0, len(r.workers))
+	for _, worker := range r.workers {
+		statuses = append(statuses, worker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// SetDraining marks the instance as shutting down; readiness fails from now on.
+func (r *Registry) SetDraining() {
+	r.draining.Store(true)
+}
+
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// SetConfig records the configuration status included in every report.
+func (r *Registry) SetConfig(status ConfigStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = &status
+}
+
+// Run executes every check concurrently, each bounded by the registry timeout.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	config := r.config
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = r.run(ctx, names[i], checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Draining: r.Draining(), Checks: results, Config: config}
+	if report.Draining {
+		report.Status = StatusDown
+	}
+	for _, result := range results {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, name string, check Check) CheckResult {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Name: name, Status: StatusUp, Latency: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Worker tracks whether a background loop is alive. The loop calls Start when
+// it begins and Stop when it returns.
+type Worker struct {
+	name    string
+	running atomic.Bool
+
+	mu                   sync.Mutex
+	startedAt, stoppedAt *time.Time
+}
+
+func (w *Worker) Start() {
+	now := time.Now()
+	w.mu.Lock()
+	w.startedAt, w.stoppedAt = &now, nil
+	w.mu.Unlock()
+	w.running.Store(true)
+}
+
+func (w *Worker) Stop() {
+	now := time.Now()
+	w.mu.Lock()
+	w.stoppedAt = &now
+	w.mu.Unlock()
+	w.running.Store(false)
+}
+
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return WorkerStatus{Name: w.name, Running: w.running.Load(), StartedAt: w.startedAt, StoppedAt: w.stoppedAt}
+}
+
+func (w *Worker) Check(ctx context.Context) error {
+	if !w.running.Load() {
+		return errors.New("not running")
+	}
+	return nil
+}
@@ -1,16 +1,74 @@
 package logging
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 var L = new(config)
 
 type config struct {
-	LogPath    string `env:"LOG_PATH,default=./assets" json:",omitempty"`
-	LogLevel   string `env:"LOG_LEVEL" json:",omitempty"`
-	LogMode    string `env:"LOG_MODE,default=development" json:",omitempty"`
-	LogSize    int    `env:"LOG_SIZE,default=10" json:",omitempty"`
-	LogBackups int    `env:"LOG_BACKUPS,default=3" json:",omitempty"`
-	LogAge     int    `env:"LOG_AGE,default=45" json:",omitempty"`
+	LogPath     string `env:"LOG_PATH,default=./assets/logger" json:",omitempty"`
+	LogLevel    string `env:"LOG_LEVEL,default=INFO" json:",omitempty"`
+	LogMode     string `env:"LOG_MODE,default=development" json:",omitempty"`
+	LogSize     int    `env:"LOG_SIZE,default=10" json:",omitempty"`
+	LogBackups  int    `env:"LOG_BACKUPS,default=3" json:",omitempty"`
+	LogAge      int    `env:"LOG_AGE,default=45" json:",omitempty"`      // days
+	LogMaxDays  int    `env:"LOG_MAX_DAYS,default=30" json:",omitempty"` // day directories kept
+	LogCompress bool   `env:"LOG_COMPRESS,default=true" json:",omitempty"`
+	LogTimezone string `env:"LOG_TIMEZONE,default=Local" json:",omitempty"`
+
+	// RedactPatterns is split on ";" since regular expressions contain commas.
+	RedactKeys     []string `env:"LOG_REDACT_KEYS,default=password,secret,token,authorization,cookie,email" json:",omitempty"`
+	RedactPatterns []string `env:"LOG_REDACT_PATTERNS,delimiter=;,default=jwt;email" json:",omitempty"`
+	RedactMode     string   `env:"LOG_REDACT_MODE,default=mask" json:",omitempty"`
+
+	Sinks Sinks `env:"LOG_SINKS" json:",omitempty"`
+
+	Sampling     SamplingRules `env:"LOG_SAMPLING" json:",omitempty"`
+	SamplingTick time.Duration `env:"LOG_SAMPLING_TICK,default=1s" json:",omitempty"`
+	DedupWindow  time.Duration `env:"LOG_DEDUP_WINDOW,default=0s" json:",omitempty"` // 0 turns deduplication off
+	DedupLevel   string        `env:"LOG_DEDUP_LEVEL,default=ERROR" json:",omitempty"`
+
+	// Requests is "all" or "errors": with "errors" only non-2xx responses and
+	// requests slower than SlowRequest are logged.
+	Requests    string        `env:"LOG_REQUESTS,default=all" json:",omitempty"`
+	SlowRequest time.Duration `env:"LOG_SLOW_REQUEST,default=1s" json:",omitempty"`
 }
 
 func (c *config) IsNotDevelopment() bool {
 	return c.LogMode != "development"
 }
+
+// RequestFilter returns the LOG_REQUESTS settings for LoggerMiddleware.
+func (c *config) RequestFilter() RequestFilter {
+	return RequestFilter{OnlyErrors: strings.EqualFold(c.Requests, "errors"), SlowerThan: c.SlowRequest}
+}
+
+// Validate checks what NewLogger would otherwise only reject at startup, and
+// reports every problem rather than the first.
+func (c *config) Validate() error {
+	var errs []error
+	if _, err := ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+	if c.DedupLevel != "" {
+		if _, err := ParseLevel(c.DedupLevel); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_DEDUP_LEVEL: %w", err))
+		}
+	}
+	if _, err := time.LoadLocation(c.LogTimezone); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_TIMEZONE: %w", err))
+	}
+	if _, err := NewRedactor(c.RedactKeys, c.RedactPatterns, c.RedactMode); err != nil {
+		errs = append(errs, err)
+	}
+	switch strings.ToLower(c.Requests) {
+	case "", "all", "errors":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_REQUESTS %q is not all or errors", c.Requests))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,255 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errSinkClosed = errors.New("log sink is closed")
+
+// HTTPSink ships encoded entries to a collector as newline-delimited JSON,
+// BatchSize entries or FlushInterval at a time. Entries wait in a buffer of
+// BufferSize; when it is full they are dropped (and counted) or, with
+// overflow "block", the logging call waits. Failed batches are retried with
+// exponential backoff on network errors, 429 and 5xx. Close waits up to
+// CloseTimeout for the rest to go out; what is still unsent then is counted
+// as failed.
+type HTTPSink struct {
+	url           string
+	headers       map[string]string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	closeTimeout  time.Duration
+	block         bool
+
+	// ctx is cancelled when Close has waited closeTimeout, which ends the
+	// request in flight and any further retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	entries chan []byte
+	flushes chan chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+func NewHTTPSink(cfg SinkConfig) (*HTTPSink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("http sink needs a url")
+	}
+	s := &HTTPSink{
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		batchSize:  cfg.BatchSize,
+		maxRetries: cfg.MaxRetries,
+		flushes:    make(chan chan struct{}),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 100
+	}
+	if s.maxRetries <= 0 {
+		s.maxRetries = 3
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	s.entries = make(chan []byte, bufferSize)
+
+	var err error
+	if s.flushInterval, err = parseDurationOr(cfg.FlushInterval, time.Second); err != nil {
+		return nil, fmt.Errorf("http sink flushInterval: %w", err)
+	}
+	if s.retryBackoff, err = parseDurationOr(cfg.RetryBackoff, 200*time.Millisecond); err != nil {
+		return nil, fmt.Errorf("http sink retryBackoff: %w", err)
+	}
+	if s.closeTimeout, err = parseDurationOr(cfg.CloseTimeout, 5*time.Second); err != nil {
+		return nil, fmt.Errorf("http sink closeTimeout: %w", err)
+	}
+	switch strings.ToLower(cfg.Overflow) {
+	case "", "drop":
+	case "block":
+		s.block = true
+	default:
+		return nil, fmt.Errorf("http sink overflow %q is not drop or block", cfg.Overflow)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s, nil
+}
+
+func parseDurationOr(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Write queues one encoded entry. zap reuses p, so it is copied.
+func (s *HTTPSink) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), bytes.TrimRight(p, "\n")...)
+	select {
+	case <-s.stop:
+		return 0, errSinkClosed
+	default:
+	}
+
+	if s.block {
+		select {
+		case s.entries <- entry:
+		case <-s.stop:
+			return 0, errSinkClosed
+		}
+		return len(p), nil
+	}
+	select {
+	case s.entries <- entry:
+	default:
+		s.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Sync sends everything queued so far and waits for it.
+func (s *HTTPSink) Sync() error {
+	done := make(chan struct{})
+	select {
+	case s.flushes <- done:
+		<-done
+		return nil
+	case <-s.stopped:
+		return nil
+	}
+}
+
+// Close sends what is left and stops the sink, giving up after closeTimeout.
+func (s *HTTPSink) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	select {
+	case <-s.stopped:
+	case <-time.After(s.closeTimeout):
+		s.cancel()
+		<-s.stopped
+	}
+	s.cancel()
+	return nil
+}
+
+// Dropped counts entries lost to a full buffer.
+func (s *HTTPSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Failed counts entries in batches that were still rejected after retrying.
+func (s *HTTPSink) Failed() int64 {
+	return s.failed.Load()
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, s.batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			s.send(batch)
+			batch = make([][]byte, 0, s.batchSize)
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case entry := <-s.entries:
+				batch = append(batch, entry)
+				if len(batch) >= s.batchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-s.flushes:
+			drain()
+			close(done)
+		case <-s.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (s *HTTPSink) send(batch [][]byte) {
+	body := append(bytes.Join(batch, []byte("\n")), '\n')
+	backoff := s.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= s.maxRetries || s.ctx.Err() != nil {
+			s.failed.Add(int64(len(batch)))
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// post reports whether a failure is worth retrying.
+func (s *HTTPSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return false, fmt.Errorf("collector answered %s", resp.Status)
+}
@@ -0,0 +1,238 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels is the level registry shared by every logger built by NewLogger.
+var Levels = NewLevelRegistry(zapcore.InfoLevel)
+
+// LevelState is one entry of the registry as shown by the admin API. An
+// empty Name is the default level.
+type LevelState struct {
+	Name      string     `json:"name"`
+	Level     string     `json:"level"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type namedLevel struct {
+	level     zap.AtomicLevel
+	expiresAt *time.Time
+	timer     *time.Timer
+	gen       int // bumped on every change so a late timer cannot undo a newer Set
+}
+
+// LevelRegistry holds the default level and per-name overrides. A logger
+// named "a.b" uses the override for "a.b", else "a", else the default.
+type LevelRegistry struct {
+	mu       sync.RWMutex
+	base     zapcore.Level
+	fallback namedLevel
+	named    map[string]*namedLevel
+	gen      int
+}
+
+func NewLevelRegistry(base zapcore.Level) *LevelRegistry {
+	return &LevelRegistry{
+		base:     base,
+		fallback: namedLevel{level: zap.NewAtomicLevelAt(base)},
+		named:    map[string]*namedLevel{},
+	}
+}
+
+// SetBase sets the default level the registry reverts to, e.g. LOG_LEVEL.
+func (r *LevelRegistry) SetBase(level zapcore.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.base = level
+	r.stop(&r.fallback)
+	r.fallback.level.SetLevel(level)
+}
+
+// Level returns the level in effect for a logger name.
+func (r *LevelRegistry) Level(name string) zapcore.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name != "" {
+		if l, ok := r.named[name]; ok {
+			return l.level.Level()
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return r.fallback.level.Level()
+}
+
+// Set changes the level of name, or the default when name is empty. With a
+// ttl the change reverts on its own: a named override is removed and the
+// default goes back to the base level.
+func (r *LevelRegistry) Set(name string, level zapcore.Level, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &r.fallback
+	if name != "" {
+		if _, ok := r.named[name]; !ok {
+			r.named[name] = &namedLevel{level: zap.NewAtomicLevel()}
+		}
+		entry = r.named[name]
+	}
+	r.stop(entry)
+	entry.level.SetLevel(level)
+
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		gen := entry.gen
+		entry.expiresAt = &expiresAt
+		entry.timer = time.AfterFunc(ttl, func() { r.expire(name, gen) })
+	}
+}
+
+func (r *LevelRegistry) expire(name string, gen int) {
+	r.mu.RLock()
+	entry := &r.fallback
+	if name != "" {
+		entry = r.named[name]
+	}
+	current := entry != nil && entry.gen == gen
+	r.mu.RUnlock()
+	if current {
+		r.Reset(name)
+	}
+}
+
+// Reset removes the override of name, or restores the base default level.
+func (r *LevelRegistry) Reset(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		r.stop(&r.fallback)
+		r.fallback.level.SetLevel(r.base)
+		return
+	}
+	if entry, ok := r.named[name]; ok {
+		r.stop(entry)
+		delete(r.named, name)
+	}
+}
+
+// List returns the default level first, then the overrides by name.
+func (r *LevelRegistry) List() []LevelState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := []LevelState{{Level: LevelName(r.fallback.level.Level()), ExpiresAt: r.fallback.expiresAt}}
+	names := make([]string, 0, len(r.named))
+	for name := range r.named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry := r.named[name]
+		states = append(states, LevelState{Name: name, Level: LevelName(entry.level.Level()), ExpiresAt: entry.expiresAt})
+	}
+	return states
+}
+
+// Enabled reports whether any logger could log at lvl, so zap only builds
+// entries someone may want.
+func (r *LevelRegistry) Enabled(lvl zapcore.Level) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.fallback.level.Enabled(lvl) {
+		return true
+	}
+	for _, entry := range r.named {
+		if entry.level.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LevelRegistry) stop(entry *namedLevel) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	entry.timer, entry.expiresAt = nil, nil
+	r.gen++
+	entry.gen = r.gen
+}
+
+// levelCore filters entries by the level registered for their logger name.
+type levelCore struct {
+	zapcore.Core
+	levels *LevelRegistry
+}
+
+func newLevelCore(core zapcore.Core, levels *LevelRegistry) zapcore.Core {
+	return &levelCore{Core: core, levels: levels}
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.levels.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.levels.Level(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// ParseLevel accepts the level names written by this package (DEBUG, INFO,
+// WARNING, ERROR, CRITICAL, ALERT, EMERGENCY).
+func ParseLevel(s string) (zapcore.Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case levelDebug:
+		return zapcore.DebugLevel, nil
+	case levelInfo:
+		return zapcore.InfoLevel, nil
+	case levelWarning:
+		return zapcore.WarnLevel, nil
+	case levelError:
+		return zapcore.ErrorLevel, nil
+	case levelCritical:
+		return zapcore.DPanicLevel, nil
+	case levelAlert:
+		return zapcore.PanicLevel, nil
+	case levelEmergency:
+		return zapcore.FatalLevel, nil
+	}
+	return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", s)
+}
+
+// LevelName is the inverse of ParseLevel.
+func LevelName(l zapcore.Level) string {
+	switch l {
+	case zapcore.DebugLevel:
+		return levelDebug
+	case zapcore.InfoLevel:
+		return levelInfo
+	case zapcore.WarnLevel:
+		return levelWarning
+	case zapcore.ErrorLevel:
+		return levelError
+	case zapcore.DPanicLevel:
+		return levelCritical
+	case zapcore.PanicLevel:
+		return levelAlert
+	case zapcore.FatalLevel:
+		return levelEmergency
+	}
+	return l.CapitalString()
+}
@@ -2,17 +2,16 @@ package logging
 
 import (
 	"backend-challenge/entities"
-	"backend-challenge/utils"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/natefinch/lumberjack"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
@@ -21,6 +20,13 @@ import (
 var (
 	defaultLogger     *zap.SugaredLogger
 	defaultLoggerOnce sync.Once
+
+	// defaultRedactor protects the bootstrap logger with the default settings.
+	defaultRedactor, _ = NewRedactor(
+		[]string{"password", "secret", "token", "authorization", "cookie", "email"},
+		[]string{"jwt", "email"},
+		RedactMask,
+	)
 )
 
 type PrettyJSONEncoder struct {
@@ -48,60 +54,74 @@ func NewPrettyJSONEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
 	return PrettyJSONEncoder{Encoder: zapcore.NewJSONEncoder(cfg)}
 }
 
-func NewLogger(level string, development bool) *zap.SugaredLogger {
-	var cores []zapcore.Core
-	var encoderConfig zapcore.EncoderConfig
-
-	nowStr := strings.Split(time.Now().Format("2006-01-02"), "-")
-	logPath := fmt.Sprintf("./assets/logger/%s_%s/%s", nowStr[0], nowStr[1], nowStr[2])
-	utils.EnsureFolderExists(logPath)
-	pathInfo := fmt.Sprintf("%s/info.json", logPath)
-	pathError := fmt.Sprintf("%s/error.json", logPath)
-	fileInfo := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   pathInfo,
-		MaxSize:    L.LogSize,
-		MaxBackups: L.LogBackups,
-		MaxAge:     L.LogAge,
-	})
-
-	fileError := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   pathError,
-		MaxSize:    L.LogSize,
-		MaxBackups: L.LogBackups,
-		MaxAge:     L.LogAge,
-	})
-	if development {
+// NewLogger builds the app logger from cfg, which must already be loaded.
+// It writes to the sinks of LOG_SINKS, by default info.json and error.json in
+// a directory per day under LOG_PATH plus a pretty stdout copy in development
+// mode. Levels come from Levels, starting at LOG_LEVEL; LOG_SAMPLING thins
+// busy levels and LOG_DEDUP_WINDOW folds repeated errors into a summary. The
+// returned func flushes and closes the sinks.
+func NewLogger(cfg *config) (*zap.SugaredLogger, func() error, error) {
+	loc, err := time.LoadLocation(cfg.LogTimezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("LOG_TIMEZONE: %w", err)
+	}
+	redactor, err := NewRedactor(cfg.RedactKeys, cfg.RedactPatterns, cfg.RedactMode)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoderConfig := productionEncoderConfig
+	if !cfg.IsNotDevelopment() {
 		encoderConfig = developmentEncoderConfig
-		cores = append(cores, zapcore.NewCore(NewPrettyJSONEncoder(encoderConfig), zapcore.AddSync(os.Stdout), zap.NewAtomicLevelAt(levelToZapLevel(level))))
-	} else {
-		encoderConfig = productionEncoderConfig
 	}
-	encoder := zapcore.NewJSONEncoder(encoderConfig)
-	cores = append(cores, zapcore.NewCore(encoder, fileInfo, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl < zapcore.ErrorLevel // Logs with level LOWER than ERROR
-	})))
+	cores, closers, err := buildSinks(cfg, loc, encoderConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ปิดข้อมูลส่วนตัวและ secret ก่อนถึง encoder ของทุก core
+	for i := range cores {
+		cores[i] = redactor.Wrap(cores[i])
+	}
 
-	// Core for error.json: logs at ERROR level and above
-	cores = append(cores, zapcore.NewCore(encoder, fileError, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.ErrorLevel // Logs with level ERROR and HIGHER
-	})))
+	dedupLevel := zapcore.ErrorLevel
+	if cfg.DedupLevel != "" {
+		if dedupLevel, err = ParseLevel(cfg.DedupLevel); err != nil {
+			return nil, nil, fmt.Errorf("LOG_DEDUP_LEVEL: %w", err)
+		}
+	}
+	core, flushDedup := newDedupCore(zapcore.NewTee(cores...), cfg.DedupWindow, dedupLevel)
+	core = newSamplingCore(core, cfg.Sampling, cfg.SamplingTick)
 
-	core := zapcore.NewTee(cores...)
+	// ทุก core ใช้ level จาก registry ตามชื่อ logger ปรับได้ตอน runtime
+	Levels.SetBase(levelToZapLevel(cfg.LogLevel))
+	core = newLevelCore(core, Levels)
 
 	logger := zap.New(core, zap.AddCaller())
 
-	return logger.Sugar()
+	closeSinks := func() error {
+		flushDedup()
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c.Close())
+		}
+		return errors.Join(errs...)
+	}
+	return logger.Sugar(), closeSinks, nil
 }
 
-func NewLoggerFromEnv() *zap.SugaredLogger {
-	level := os.Getenv("LOG_LEVEL")
-	development := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_MODE"))) == "development"
-	return NewLogger(level, development)
+// SetDefault replaces the logger used when a context carries none.
+func SetDefault(logger *zap.SugaredLogger) {
+	defaultLoggerOnce.Do(func() {})
+	defaultLogger = logger
 }
 
+// DefaultLogger is used when a context carries no logger. Until SetDefault is
+// called it only writes to stderr, since the file settings may not be loaded.
 func DefaultLogger() *zap.SugaredLogger {
 	defaultLoggerOnce.Do(func() {
-		defaultLogger = NewLoggerFromEnv()
+		encoder := zapcore.NewJSONEncoder(productionEncoderConfig)
+		core := defaultRedactor.Wrap(zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), levelToZapLevel(os.Getenv("LOG_LEVEL"))))
+		defaultLogger = zap.New(core, zap.AddCaller()).Sugar()
 	})
 	return defaultLogger
 }
@@ -110,11 +130,17 @@ func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context
 	return context.WithValue(ctx, entities.LoggerKey, logger)
 }
 
+// FromContext returns the request logger, tagged with the trace and span id
+// when the context carries a span.
 func FromContext(ctx context.Context) *zap.SugaredLogger {
-	if logger, ok := ctx.Value(entities.LoggerKey).(*zap.SugaredLogger); ok {
-		return logger
+	logger, ok := ctx.Value(entities.LoggerKey).(*zap.SugaredLogger)
+	if !ok {
+		logger = DefaultLogger()
 	}
-	return DefaultLogger()
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		return logger.With("trace_id", span.TraceID().String(), "span_id", span.SpanID().String())
+	}
+	return logger
 }
 
 func FromContextWithName(ctx context.Context, name string) *zap.SugaredLogger {
@@ -167,23 +193,9 @@ var developmentEncoderConfig = zapcore.EncoderConfig{
 }
 
 func levelToZapLevel(s string) zapcore.Level {
-	switch strings.ToUpper(strings.TrimSpace(s)) {
-	case levelDebug:
-		return zapcore.DebugLevel
-	case levelInfo:
-		return zapcore.InfoLevel
-	case levelWarning:
-		return zapcore.WarnLevel
-	case levelError:
-		return zapcore.ErrorLevel
-	case levelCritical:
-		return zapcore.DPanicLevel
-	case levelAlert:
-		return zapcore.PanicLevel
-	case levelEmergency:
-		return zapcore.FatalLevel
+	if level, err := ParseLevel(s); err == nil {
+		return level
 	}
-
 	return zapcore.WarnLevel
 }
 
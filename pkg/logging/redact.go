@@ -0,0 +1,246 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	RedactMask    = "mask"    // replace the whole value
+	RedactPartial = "partial" // keep a short prefix (or the email domain)
+	RedactHash    = "hash"    // stable digest, so values can still be correlated
+
+	redacted = "[REDACTED]"
+)
+
+// redactPatterns are the built-in patterns LOG_REDACT_PATTERNS may name.
+// Anything else in that list is compiled as a regular expression.
+var redactPatterns = map[string]string{
+	"jwt":   `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	"email": `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@([^@\s]+)$`)
+
+// Redactor masks sensitive values before they reach an encoder: fields whose
+// key contains one of keys, at any depth of a logged object, and substrings of
+// messages and string values that match one of patterns.
+type Redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+	mode     string
+}
+
+func NewRedactor(keys, patterns []string, mode string) (*Redactor, error) {
+	r := &Redactor{mode: strings.ToLower(strings.TrimSpace(mode))}
+	switch r.mode {
+	case "":
+		r.mode = RedactMask
+	case RedactMask, RedactPartial, RedactHash:
+	default:
+		return nil, fmt.Errorf("unknown LOG_REDACT_MODE %q", mode)
+	}
+
+	for _, key := range keys {
+		if key = normalizeKey(key); key != "" {
+			r.keys = append(r.keys, key)
+		}
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if builtin, ok := redactPatterns[strings.ToLower(pattern)]; ok {
+			pattern = builtin
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("LOG_REDACT_PATTERNS %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Wrap returns core with redaction applied to everything written through it.
+func (r *Redactor) Wrap(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: core, redactor: r}
+}
+
+// normalizeKey lets "accessToken", "access_token" and "Access-Token" all
+// match the key "token".
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.TrimSpace(key)))
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask hides a whole value according to the mode.
+func (r *Redactor) mask(value string) string {
+	switch r.mode {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case RedactPartial:
+		if m := emailPattern.FindStringSubmatch(value); m != nil {
+			return value[:1] + "***@" + m[1]
+		}
+		if len(value) > 8 {
+			return value[:4] + "***"
+		}
+		return "***"
+	}
+	return redacted
+}
+
+// scrub masks the parts of s that match a pattern.
+func (r *Redactor) scrub(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, r.mask)
+	}
+	return s
+}
+
+// value redacts a decoded JSON-like value, walking maps and slices.
+func (r *Redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.scrub(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if r.sensitiveKey(k) {
+				out[k] = r.mask(fmt.Sprint(item))
+			} else {
+				out[k] = r.value(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.value(item)
+		}
+		return out
+	}
+	return v
+}
+
+func (r *Redactor) field(f zapcore.Field) zapcore.Field {
+	if f.Type == zapcore.NamespaceType || f.Type == zapcore.SkipType {
+		return f
+	}
+	if r.sensitiveKey(f.Key) {
+		return zap.String(f.Key, r.mask(fieldString(f)))
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		return zap.String(f.Key, r.scrub(f.String))
+	case zapcore.ByteStringType:
+		return zap.String(f.Key, r.scrub(string(f.Interface.([]byte))))
+	case zapcore.ErrorType:
+		return zap.String(f.Key, r.scrub(f.Interface.(error).Error()))
+	case zapcore.StringerType:
+		return zap.String(f.Key, r.scrub(f.Interface.(fmt.Stringer).String()))
+	case zapcore.ReflectType:
+		// round-trip through JSON, which is how the encoder would print it
+		data, err := json.Marshal(f.Interface)
+		if err != nil {
+			return zap.String(f.Key, redacted)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return zap.String(f.Key, redacted)
+		}
+		return zap.Any(f.Key, r.value(decoded))
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return zap.Any(f.Key, r.value(normalize(enc.Fields[f.Key])))
+	}
+	return f
+}
+
+// fieldString renders a field's value the way it would appear in the log.
+func fieldString(f zapcore.Field) string {
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	if v, ok := enc.Fields[f.Key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(f.Interface)
+}
+
+// normalize turns the output of a MapObjectEncoder into plain maps and slices.
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return redacted
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return redacted
+	}
+	return decoded
+}
+
+func (r *Redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		if f.Type != zapcore.InlineMarshalerType {
+			out = append(out, r.field(f))
+			continue
+		}
+		// inline objects add their keys to the entry itself
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		for k, v := range enc.Fields {
+			out = append(out, r.field(zap.Any(k, normalize(v))))
+		}
+	}
+	return out
+}
+
+type redactCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactor.fields(fields)), redactor: c.redactor}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.scrub(ent.Message)
+	return c.Core.Write(ent, c.redactor.fields(fields))
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"time"
+)
+
+// RequestFilter decides which requests LoggerMiddleware logs. The zero value
+// logs every request.
+type RequestFilter struct {
+	OnlyErrors bool
+	SlowerThan time.Duration // with OnlyErrors, successful requests at least this slow are kept; 0 keeps none
+}
+
+func (f RequestFilter) Keep(status int, latency time.Duration) bool {
+	if !f.OnlyErrors || status < 200 || status >= 300 {
+		return true
+	}
+	return f.SlowerThan > 0 && latency >= f.SlowerThan
+}
+
+// WithQuietSuccess marks ctx so successful responses are not logged on their
+// own; the request line already covers the ones worth keeping.
+func WithQuietSuccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, entities.QuietSuccessKey, true)
+}
+
+func QuietSuccess(ctx context.Context) bool {
+	quiet, _ := ctx.Value(entities.QuietSuccessKey).(bool)
+	return quiet
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+)
+
+// dayLayout is the directory of one day under the log root: YYYY_MM/DD.
+const dayLayout = "2006_01/02"
+
+// DailyWriter writes to <Root>/<YYYY_MM>/<DD>/<Name> and moves to a new
+// directory at midnight in Location. Within a day, files are still rotated by
+// size. When the day changes, files of past days are gzipped and days older
+// than MaxAge or beyond the newest MaxDays are removed.
+type DailyWriter struct {
+	Root     string
+	Name     string
+	Location *time.Location
+
+	MaxSize    int // megabytes per file within a day
+	MaxBackups int // size-rotated files kept within a day
+	MaxAge     time.Duration
+	MaxDays    int
+	Compress   bool
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	day     string
+	current *lumberjack.Logger
+	cleanup sync.WaitGroup
+}
+
+func (w *DailyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock()
+	day := now.In(w.location()).Format(dayLayout)
+	if day != w.day || w.current == nil {
+		if err := w.open(day); err != nil {
+			return 0, err
+		}
+		w.cleanup.Add(1)
+		go func() {
+			defer w.cleanup.Done()
+			w.tidy(now)
+		}()
+	}
+	return w.current.Write(p)
+}
+
+func (w *DailyWriter) Sync() error {
+	return nil
+}
+
+// Close closes the current file and waits for a running cleanup.
+func (w *DailyWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cleanup.Wait()
+	if w.current == nil {
+		return nil
+	}
+	err := w.current.Close()
+	w.current = nil
+	return err
+}
+
+func (w *DailyWriter) open(day string) error {
+	if w.current != nil {
+		w.current.Close()
+	}
+	dir := filepath.Join(w.Root, filepath.FromSlash(day))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create log directory: %w", err)
+	}
+	w.day = day
+	w.current = &lumberjack.Logger{
+		Filename:   filepath.Join(dir, w.Name),
+		MaxSize:    w.MaxSize,
+		MaxBackups: w.MaxBackups,
+	}
+	return nil
+}
+
+// tidy compresses and prunes the files this writer left in past days. Files
+// of other writers sharing the root are left alone.
+func (w *DailyWriter) tidy(now time.Time) {
+	loc := w.location()
+	today := now.In(loc).Format(dayLayout)
+	prefix := strings.TrimSuffix(w.Name, filepath.Ext(w.Name))
+
+	dirs, _ := filepath.Glob(filepath.Join(w.Root, "*_*", "*"))
+	var days []string
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(w.Root, dir)
+		if err != nil {
+			continue
+		}
+		day := filepath.ToSlash(rel)
+		if _, err := time.ParseInLocation(dayLayout, day, loc); err != nil || day >= today {
+			continue
+		}
+		days = append(days, day)
+	}
+	// newest first; today counts towards MaxDays
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+
+	for i, day := range days {
+		dir := filepath.Join(w.Root, filepath.FromSlash(day))
+		start, _ := time.ParseInLocation(dayLayout, day, loc)
+		expired := (w.MaxAge > 0 && now.Sub(start.AddDate(0, 0, 1)) > w.MaxAge) ||
+			(w.MaxDays > 0 && i+1 >= w.MaxDays)
+
+		files, _ := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		for _, file := range files {
+			switch {
+			case expired:
+				os.Remove(file)
+			case w.Compress && !strings.HasSuffix(file, ".gz"):
+				gzipFile(file)
+			}
+		}
+		if expired {
+			// only succeeds once every writer has cleared its files
+			os.Remove(dir)
+			os.Remove(filepath.Dir(dir))
+		}
+	}
+}
+
+func (w *DailyWriter) clock() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
+
+func (w *DailyWriter) location() *time.Location {
+	if w.Location != nil {
+		return w.Location
+	}
+	return time.Local
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
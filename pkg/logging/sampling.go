@@ -0,0 +1,259 @@
+package logging
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SampleRule keeps the First entries with the same level and message in each
+// tick, then every Thereafter-th one (none when Thereafter is 0).
+type SampleRule struct {
+	First      int
+	Thereafter int
+}
+
+// SamplingRules is LOG_SAMPLING, e.g. "DEBUG=10/0,INFO=100/10". Levels that
+// are not listed are never sampled.
+type SamplingRules map[zapcore.Level]SampleRule
+
+func (r *SamplingRules) EnvDecode(value string) error {
+	rules := SamplingRules{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, rule, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("LOG_SAMPLING %q must look like INFO=100/10", part)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("LOG_SAMPLING: %w", err)
+		}
+		first, thereafter, _ := strings.Cut(rule, "/")
+		var sample SampleRule
+		if sample.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || sample.First < 0 {
+			return fmt.Errorf("LOG_SAMPLING %q: first must be a positive number", part)
+		}
+		if thereafter != "" {
+			if sample.Thereafter, err = strconv.Atoi(strings.TrimSpace(thereafter)); err != nil || sample.Thereafter < 0 {
+				return fmt.Errorf("LOG_SAMPLING %q: thereafter must be a positive number", part)
+			}
+		}
+		rules[level] = sample
+	}
+	*r = rules
+	return nil
+}
+
+// samplingCore routes each level to its own zap sampler, so INFO can be thinned
+// hard while WARNING and above are kept whole.
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+func newSamplingCore(core zapcore.Core, rules SamplingRules, tick time.Duration) zapcore.Core {
+	if len(rules) == 0 {
+		return core
+	}
+	if tick <= 0 {
+		tick = time.Second
+	}
+	samplers := make(map[zapcore.Level]zapcore.Core, len(rules))
+	for level, rule := range rules {
+		thereafter := rule.Thereafter
+		if thereafter == 0 {
+			thereafter = int(^uint(0) >> 1) // drop everything past First
+		}
+		samplers[level] = zapcore.NewSamplerWithOptions(core, tick, rule.First, thereafter)
+	}
+	return &samplingCore{Core: core, samplers: samplers}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	// zap samplers share their counters with the cores their With returns
+	samplers := make(map[zapcore.Level]zapcore.Core, len(c.samplers))
+	for level, sampler := range c.samplers {
+		samplers[level] = sampler.With(fields)
+	}
+	return &samplingCore{Core: c.Core.With(fields), samplers: samplers}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if sampler, ok := c.samplers[ent.Level]; ok {
+		return sampler.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// dedupCore writes the first of a run of identical messages at or above
+// minLevel and swallows the rest for one window. When the window ends a
+// summary with the number suppressed is written in their place. Messages are
+// identical when their level, logger, message and dedupFields match, so
+// "response error" lines for different errors are kept apart.
+type dedupCore struct {
+	zapcore.Core
+	state *dedupState
+}
+
+type dedupState struct {
+	window   time.Duration
+	minLevel zapcore.Level
+
+	mu      sync.Mutex
+	pending map[dedupKey]*dedupEntry
+	closed  bool
+}
+
+type dedupKey struct {
+	level   zapcore.Level
+	logger  string
+	message string
+	fields  string
+}
+
+// dedupFields tell errors logged with the same message apart. Details that
+// change on every call, such as ids or attempts, are left out.
+var dedupFields = map[string]bool{
+	"error":         true,
+	"error_code":    true,
+	"error_message": true,
+	"function":      true,
+	"status_code":   true,
+}
+
+type dedupEntry struct {
+	core       zapcore.Core
+	entry      zapcore.Entry
+	fields     []zapcore.Field
+	suppressed int
+	timer      *time.Timer
+}
+
+func newDedupCore(core zapcore.Core, window time.Duration, minLevel zapcore.Level) (zapcore.Core, func()) {
+	if window <= 0 {
+		return core, func() {}
+	}
+	state := &dedupState{window: window, minLevel: minLevel, pending: map[dedupKey]*dedupEntry{}}
+	return &dedupCore{Core: core, state: state}, state.flush
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), state: c.state}
+}
+
+// Check defers to Write for the levels it deduplicates, the fields that are
+// part of the key are only known there.
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.state.minLevel {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	s := c.state
+	keyFields, key := dedupKeyOf(ent, fields)
+	s.mu.Lock()
+	if pending, ok := s.pending[key]; ok {
+		pending.suppressed++
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.closed {
+		pending := &dedupEntry{core: c.Core, entry: ent, fields: keyFields}
+		s.pending[key] = pending
+		pending.timer = time.AfterFunc(s.window, func() { s.expire(key, pending) })
+	}
+	s.mu.Unlock()
+
+	// through Check, so each core of a tee keeps its own level
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+func dedupKeyOf(ent zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, dedupKey) {
+	var keyFields []zapcore.Field
+	var values strings.Builder
+	for _, f := range fields {
+		if !dedupFields[f.Key] {
+			continue
+		}
+		keyFields = append(keyFields, f)
+		fmt.Fprintf(&values, "%s=%s;", f.Key, dedupValue(f))
+	}
+	return keyFields, dedupKey{level: ent.Level, logger: ent.LoggerName, message: ent.Message, fields: values.String()}
+}
+
+func dedupValue(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(f.Integer, 10)
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return err.Error()
+		}
+	}
+	if f.Interface != nil {
+		return fmt.Sprint(f.Interface)
+	}
+	return strconv.FormatInt(f.Integer, 10)
+}
+
+func (s *dedupState) expire(key dedupKey, pending *dedupEntry) {
+	s.mu.Lock()
+	if s.pending[key] != pending {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, key)
+	s.mu.Unlock()
+	s.summarize(pending)
+}
+
+// flush writes the summaries still waiting for their window, before the
+// sinks are closed.
+func (s *dedupState) flush() {
+	s.mu.Lock()
+	s.closed = true
+	pending := s.pending
+	s.pending = map[dedupKey]*dedupEntry{}
+	s.mu.Unlock()
+
+	for _, p := range pending {
+		p.timer.Stop()
+		s.summarize(p)
+	}
+}
+
+func (s *dedupState) summarize(p *dedupEntry) {
+	if p.suppressed == 0 {
+		return
+	}
+	ent := p.entry
+	ent.Time = time.Now()
+	ent.Message = fmt.Sprintf("%s (repeated %d more times)", p.entry.Message, p.suppressed)
+	ent.Stack = ""
+	if ce := p.core.Check(ent, nil); ce != nil {
+		ce.Write(append(p.fields,
+			zap.String("original_message", p.entry.Message),
+			zap.Int("suppressed", p.suppressed),
+			zap.Duration("window", s.window),
+		)...)
+	}
+}
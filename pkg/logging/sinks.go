@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkSyslog = "syslog"
+	SinkHTTP   = "http"
+)
+
+// SinkConfig declares one log destination. Only the fields of its Type are
+// read.
+type SinkConfig struct {
+	Type    string `json:"type"`
+	Encoder string `json:"encoder,omitempty"` // json (default), console or pretty
+	Level   string `json:"level,omitempty"`   // minimum level; every level when empty
+
+	// syslog and http
+	BufferSize int `json:"bufferSize,omitempty"`
+
+	// syslog
+	Address  string `json:"address,omitempty"` // udp://host:514, tcp://host:601 or unix:///dev/log
+	Facility int    `json:"facility,omitempty"`
+	AppName  string `json:"appName,omitempty"`
+
+	// http
+	URL           string            `json:"url,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	BatchSize     int               `json:"batchSize,omitempty"`
+	FlushInterval string            `json:"flushInterval,omitempty"`
+	MaxRetries    int               `json:"maxRetries,omitempty"`
+	RetryBackoff  string            `json:"retryBackoff,omitempty"`
+	Overflow      string            `json:"overflow,omitempty"` // drop (default) or block
+	CloseTimeout  string            `json:"closeTimeout,omitempty"`
+}
+
+// Sinks is LOG_SINKS, a JSON array of SinkConfig, e.g.
+// [{"type":"stderr"},{"type":"http","url":"http://collector:8080/logs","level":"WARNING"}].
+type Sinks []SinkConfig
+
+func (s *Sinks) EnvDecode(value string) error {
+	if strings.TrimSpace(value) == "" {
+		*s = nil
+		return nil
+	}
+	var sinks []SinkConfig
+	if err := json.Unmarshal([]byte(value), &sinks); err != nil {
+		return fmt.Errorf("LOG_SINKS must be a JSON array: %w", err)
+	}
+	*s = sinks
+	return nil
+}
+
+// defaultSinks keeps the historical setup when LOG_SINKS is not set: daily
+// files, plus pretty stdout in development mode.
+func defaultSinks(cfg *config) Sinks {
+	sinks := Sinks{{Type: SinkFile}}
+	if !cfg.IsNotDevelopment() {
+		sinks = append(Sinks{{Type: SinkStdout, Encoder: "pretty"}}, sinks...)
+	}
+	return sinks
+}
+
+// buildSinks turns the sink declarations into cores. The closers release
+// files, connections and HTTP buffers.
+func buildSinks(cfg *config, loc *time.Location, encoderConfig zapcore.EncoderConfig) ([]zapcore.Core, []io.Closer, error) {
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = defaultSinks(cfg)
+	}
+
+	var cores []zapcore.Core
+	var closers []io.Closer
+	fail := func(err error) ([]zapcore.Core, []io.Closer, error) {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, nil, err
+	}
+
+	for i, sink := range sinks {
+		encoder, err := newEncoder(sink.Encoder, encoderConfig)
+		if err != nil {
+			return fail(fmt.Errorf("LOG_SINKS[%d]: %w", i, err))
+		}
+		level := zapcore.DebugLevel
+		if sink.Level != "" {
+			if level, err = ParseLevel(sink.Level); err != nil {
+				return fail(fmt.Errorf("LOG_SINKS[%d]: %w", i, err))
+			}
+		}
+
+		switch strings.ToLower(sink.Type) {
+		case SinkFile:
+			fileInfo, fileError := newDailyWriter(cfg, loc, "info.json"), newDailyWriter(cfg, loc, "error.json")
+			closers = append(closers, fileInfo, fileError)
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(fileInfo), zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl >= level && lvl < zapcore.ErrorLevel // Logs with level LOWER than ERROR
+			})))
+			// Core for error.json: logs at ERROR level and above
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(fileError), zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl >= level && lvl >= zapcore.ErrorLevel // Logs with level ERROR and HIGHER
+			})))
+		case SinkStdout:
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), level))
+		case SinkStderr:
+			cores = append(cores, zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level))
+		case SinkSyslog:
+			writer, err := NewSyslogWriter(sink)
+			if err != nil {
+				return fail(fmt.Errorf("LOG_SINKS[%d]: %w", i, err))
+			}
+			closers = append(closers, writer)
+			cores = append(cores, newSyslogCore(encoder, writer, level))
+		case SinkHTTP:
+			httpSink, err := NewHTTPSink(sink)
+			if err != nil {
+				return fail(fmt.Errorf("LOG_SINKS[%d]: %w", i, err))
+			}
+			closers = append(closers, httpSink)
+			cores = append(cores, zapcore.NewCore(encoder, httpSink, level))
+		default:
+			return fail(fmt.Errorf("LOG_SINKS[%d]: unknown sink type %q", i, sink.Type))
+		}
+	}
+	return cores, closers, nil
+}
+
+func newEncoder(name string, cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	switch strings.ToLower(name) {
+	case "", "json":
+		return zapcore.NewJSONEncoder(cfg), nil
+	case "console":
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case "pretty":
+		return NewPrettyJSONEncoder(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown encoder %q", name)
+}
+
+func newDailyWriter(cfg *config, loc *time.Location, name string) *DailyWriter {
+	return &DailyWriter{
+		Root:       cfg.LogPath,
+		Name:       name,
+		Location:   loc,
+		MaxSize:    cfg.LogSize,
+		MaxBackups: cfg.LogBackups,
+		MaxAge:     time.Duration(cfg.LogAge) * 24 * time.Hour,
+		MaxDays:    cfg.LogMaxDays,
+		Compress:   cfg.LogCompress,
+	}
+}
@@ -0,0 +1,264 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogWriter sends RFC 5424 messages over UDP, TCP (octet-counting framing,
+// RFC 6587) or a unix socket. Messages wait in a buffer of BufferSize and are
+// sent by a background goroutine, so a slow or unreachable server never holds
+// up the logging call. A failed write is retried once on a new connection;
+// after a failed dial messages are dropped (and counted) until the next
+// attempt, which backs off from one second to a minute.
+type SyslogWriter struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	pid      int
+
+	frames  chan []byte
+	flushes chan chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	// dialCtx is cancelled when Close has waited syslogTimeout, so an
+	// unreachable server cannot hold up shutdown with new dials
+	dialCtx    context.Context
+	cancelDial context.CancelFunc
+
+	// owned by run
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+
+	dropped atomic.Int64
+}
+
+const (
+	syslogTimeout    = 5 * time.Second
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = time.Minute
+)
+
+func NewSyslogWriter(cfg SinkConfig) (*SyslogWriter, error) {
+	u, err := url.Parse(cfg.Address)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("syslog address %q must look like udp://host:514", cfg.Address)
+	}
+	w := &SyslogWriter{network: u.Scheme, address: u.Host, facility: cfg.Facility, appName: cfg.AppName, pid: os.Getpid()}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "unix", "unixgram":
+		w.network, w.address = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("syslog network %q is not udp, tcp or unix", u.Scheme)
+	}
+	if w.facility == 0 {
+		w.facility = 1 // user-level messages
+	}
+	if w.appName == "" {
+		w.appName = filepath.Base(os.Args[0])
+	}
+	if w.hostname, err = os.Hostname(); err != nil {
+		w.hostname = "-"
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+	w.frames = make(chan []byte, bufferSize)
+	w.flushes = make(chan chan struct{})
+	w.stop = make(chan struct{})
+	w.stopped = make(chan struct{})
+	w.dialCtx, w.cancelDial = context.WithCancel(context.Background())
+
+	go w.run()
+	return w, nil
+}
+
+// WriteMessage frames msg for the given zap level and queues it. It drops the
+// message when the buffer is full.
+func (w *SyslogWriter) WriteMessage(level zapcore.Level, t time.Time, msg []byte) error {
+	var frame bytes.Buffer
+	fmt.Fprintf(&frame, "<%d>1 %s %s %s %d - - ",
+		w.facility*8+syslogSeverity(level), t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), w.hostname, w.appName, w.pid)
+	frame.Write(bytes.TrimRight(msg, "\n"))
+
+	packet := frame.Bytes()
+	if w.network == "tcp" {
+		packet = append([]byte(fmt.Sprintf("%d ", frame.Len())), packet...)
+	}
+
+	select {
+	case <-w.stop:
+		return errSinkClosed
+	default:
+	}
+	select {
+	case w.frames <- packet:
+	default:
+		w.dropped.Add(1)
+	}
+	return nil
+}
+
+// Sync sends everything queued so far and waits for it.
+func (w *SyslogWriter) Sync() error {
+	done := make(chan struct{})
+	select {
+	case w.flushes <- done:
+		<-done
+	case <-w.stopped:
+	}
+	return nil
+}
+
+// Close sends what is left and closes the connection.
+func (w *SyslogWriter) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	select {
+	case <-w.stopped:
+	case <-time.After(syslogTimeout):
+		w.cancelDial()
+		<-w.stopped
+	}
+	w.cancelDial()
+	return nil
+}
+
+// Dropped counts messages lost to a full buffer or an unreachable server.
+func (w *SyslogWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+func (w *SyslogWriter) run() {
+	defer close(w.stopped)
+	drain := func() {
+		for {
+			select {
+			case packet := <-w.frames:
+				w.send(packet)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case packet := <-w.frames:
+			w.send(packet)
+		case done := <-w.flushes:
+			drain()
+			close(done)
+		case <-w.stop:
+			drain()
+			if w.conn != nil {
+				w.conn.Close()
+			}
+			return
+		}
+	}
+}
+
+func (w *SyslogWriter) send(packet []byte) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil && !w.dial() {
+			break
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err := w.conn.Write(packet); err == nil {
+			return
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	w.dropped.Add(1)
+}
+
+// dial connects unless the last attempt failed too recently.
+func (w *SyslogWriter) dial() bool {
+	if time.Now().Before(w.retryAt) {
+		return false
+	}
+	dialer := net.Dialer{Timeout: syslogTimeout}
+	conn, err := dialer.DialContext(w.dialCtx, w.network, w.address)
+	if err != nil {
+		w.backoff = min(max(w.backoff*2, syslogMinBackoff), syslogMaxBackoff)
+		w.retryAt = time.Now().Add(w.backoff)
+		return false
+	}
+	w.conn, w.backoff, w.retryAt = conn, 0, time.Time{}
+	return true
+}
+
+func syslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	}
+	return 0 // fatal: emergency
+}
+
+// syslogCore needs the entry level for the PRI field, which a plain
+// WriteSyncer never sees.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *SyslogWriter
+}
+
+func newSyslogCore(encoder zapcore.Encoder, writer *SyslogWriter, level zapcore.LevelEnabler) zapcore.Core {
+	return &syslogCore{LevelEnabler: level, encoder: encoder, writer: writer}
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for _, f := range fields {
+		f.AddTo(encoder)
+	}
+	return &syslogCore{LevelEnabler: c.LevelEnabler, encoder: encoder, writer: c.writer}
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	return c.writer.WriteMessage(ent.Level, ent.Time, buf.Bytes())
+}
+
+func (c *syslogCore) Sync() error {
+	return c.writer.Sync()
+}
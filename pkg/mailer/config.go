@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var M = new(config)
+
+type config struct {
+	Driver       string `env:"MAIL_DRIVER,default=file" json:",omitempty"`
+	From         string `env:"MAIL_FROM,default=no-reply@backend-challenge.local" json:",omitempty"`
+	SMTPHost     string `env:"SMTP_HOST" json:",omitempty"`
+	SMTPPort     string `env:"SMTP_PORT,default=587" json:",omitempty"`
+	SMTPUsername string `env:"SMTP_USERNAME" json:",omitempty"`
+	SMTPPassword string `env:"SMTP_PASSWORD" json:"-"`
+	DropDir      string `env:"MAIL_DROP_DIR,default=./assets/mail" json:",omitempty"`
+}
+
+func (c *config) Validate() error {
+	switch strings.ToLower(strings.TrimSpace(c.Driver)) {
+	case DriverSMTP:
+		if c.SMTPHost == "" {
+			return errors.New("SMTP_HOST is required for MAIL_DRIVER smtp")
+		}
+	case DriverFile, DriverMemory, "":
+	default:
+		return fmt.Errorf("MAIL_DRIVER %q is not smtp, file or memory", c.Driver)
+	}
+	return nil
+}
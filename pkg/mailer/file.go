@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"backend-challenge/utils"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer drops every message as an .eml file into a directory, which is
+// handy for local development where no SMTP relay is available.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	msg = withRequestID(ctx, withDefaultFrom(msg, m.from))
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("file mailer build message: %w", err)
+	}
+
+	if err := utils.EnsureFolderExists(m.dir); err != nil {
+		return fmt.Errorf("file mailer: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0644)
+}
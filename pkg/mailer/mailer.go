@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"backend-challenge/entities"
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+// Mailer delivers a rendered message. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+func New(cfg *config) (Mailer, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Driver)) {
+	case DriverSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("mailer: SMTP_HOST is required for the smtp driver")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case DriverFile, "":
+		return NewFileMailer(cfg.DropDir, cfg.From), nil
+	case DriverMemory:
+		return NewMemoryMailer(cfg.From), nil
+	}
+
+	return nil, fmt.Errorf("mailer: unknown driver %q", cfg.Driver)
+}
+
+// Bytes renders the message as a MIME multipart/alternative document.
+func (m Message) Bytes() ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	}
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", m.From)
+	fmt.Fprintf(&out, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&out, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	for k, v := range m.Headers {
+		fmt.Fprintf(&out, "%s: %s\r\n", k, v)
+	}
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	out.Write(body.Bytes())
+
+	return out.Bytes(), nil
+}
+
+func withDefaultFrom(msg Message, from string) Message {
+	if msg.From == "" {
+		msg.From = from
+	}
+	return msg
+}
+
+// withRequestID stamps the message with the id of the request that sent it,
+// so a bounced or delayed mail can be traced back to the request log.
+func withRequestID(ctx context.Context, msg Message) Message {
+	requestID, _ := ctx.Value(entities.RequestId).(string)
+	if requestID == "" {
+		return msg
+	}
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[entities.RequestIDHeader] = requestID
+	msg.Headers = headers
+	return msg
+}
@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer keeps sent messages in memory so tests can assert on them.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	from     string
+	messages []Message
+}
+
+func NewMemoryMailer(from string) *MemoryMailer {
+	return &MemoryMailer{from: from}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, withRequestID(ctx, withDefaultFrom(msg, m.from)))
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: net.JoinHostPort(host, port), host: host, from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	msg = withRequestID(ctx, withDefaultFrom(msg, m.from))
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("smtp build message: %w", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- smtp.SendMail(m.addr, m.auth, msg.From, msg.To, data)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("smtp send: %w", err)
+		}
+		return nil
+	}
+}
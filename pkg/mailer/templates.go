@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// Render builds a message from the "<name>.txt" and "<name>.html" templates.
+// The subject comes from the "subject" block of the text template.
+func Render(name string, to string, data interface{}) (Message, error) {
+	var subject, text, html bytes.Buffer
+
+	textTmpl := textTemplates.Lookup(name + ".txt")
+	if textTmpl == nil {
+		return Message{}, fmt.Errorf("mailer: template %s.txt not found", name)
+	}
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("mailer: render subject %s: %w", name, err)
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("mailer: render %s.txt: %w", name, err)
+	}
+
+	if htmlTmpl := htmlTemplates.Lookup(name + ".html"); htmlTmpl != nil {
+		if err := htmlTmpl.Execute(&html, data); err != nil {
+			return Message{}, fmt.Errorf("mailer: render %s.html: %w", name, err)
+		}
+	}
+
+	return Message{
+		To:      []string{to},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    html.String(),
+	}, nil
+}
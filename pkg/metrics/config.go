@@ -0,0 +1,11 @@
+package metrics
+
+var M = new(config)
+
+type config struct {
+	Enabled bool   `env:"METRICS_ENABLED,default=true" json:",omitempty"`
+	Path    string `env:"METRICS_PATH,default=/metrics" json:",omitempty"`
+	// Addr is the former name of ADMIN_ADDR, still honored when ADMIN_ADDR
+	// is not set. Metrics move to the admin listener when either is set.
+	Addr string `env:"METRICS_ADDR" json:",omitempty"`
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of the app plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	MongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "MongoDB command latency by command and collection.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "collection"})
+
+	MongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_command_errors_total",
+		Help: "Failed MongoDB commands by command and collection.",
+	}, []string{"command", "collection"})
+
+	Users = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "users",
+		Help: "Number of registered users.",
+	})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by flow and result.",
+	}, []string{"function", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		MongoDuration,
+		MongoErrors,
+		Users,
+		Logins,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoMonitor records the latency and errors of every command sent by the
+// client, so repository methods need no instrumentation of their own.
+func MongoMonitor() *event.CommandMonitor {
+	// the collection is only known from the started event
+	var collections sync.Map
+
+	finished := func(e event.CommandFinishedEvent, failed bool) {
+		collection := ""
+		if v, ok := collections.LoadAndDelete(e.RequestID); ok {
+			collection = v.(string)
+		}
+		MongoDuration.WithLabelValues(e.CommandName, collection).Observe(e.Duration.Seconds())
+		if failed {
+			MongoErrors.WithLabelValues(e.CommandName, collection).Inc()
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, true)
+		},
+	}
+}
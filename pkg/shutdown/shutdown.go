@@ -0,0 +1,120 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type phase struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// Manager runs shutdown phases in the order they were added. Every phase gets
+// its own bounded context detached from the caller's, which is usually the
+// signal context and already cancelled by the time shutdown starts.
+type Manager struct {
+	logger *zap.SugaredLogger
+	phases []phase
+}
+
+func NewManager(logger *zap.SugaredLogger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// Add appends a phase. A zero timeout leaves the phase unbounded.
+func (m *Manager) Add(name string, timeout time.Duration, run func(ctx context.Context) error) {
+	m.phases = append(m.phases, phase{name: name, timeout: timeout, run: run})
+}
+
+// Shutdown runs every phase even if an earlier one fails, so a stuck HTTP
+// drain still lets Mongo be closed, and returns the joined errors.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	start := time.Now()
+
+	var errs []error
+	for _, p := range m.phases {
+		phaseStart := time.Now()
+		err := m.run(ctx, p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			m.logger.Errorw("shutdown phase failed", "phase", p.name, "duration", time.Since(phaseStart).String(), "error", err)
+			continue
+		}
+		m.logger.Infow("shutdown phase done", "phase", p.name, "duration", time.Since(phaseStart).String())
+	}
+
+	m.logger.Infow("shutdown finished", "duration", time.Since(start).String(), "failed_phases", len(errs))
+	return errors.Join(errs...)
+}
+
+func (m *Manager) run(ctx context.Context, p phase) error {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	return p.run(ctx)
+}
+
+// Delay is a phase that waits d, e.g. to let load balancers notice a failing
+// readiness probe before connections are closed.
+func Delay(d time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Workers runs background loops on a context of their own, so they keep
+// working while requests drain and only stop when Stop is called.
+type Workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorkers keeps the values of ctx (logger, etc.) but not its cancellation.
+func NewWorkers(ctx context.Context) *Workers {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return &Workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine; fn must return once its context is done.
+func (w *Workers) Go(fn func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, or for ctx to end.
+func (w *Workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers did not stop: %w", ctx.Err())
+	}
+}
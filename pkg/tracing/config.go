@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+)
+
+var T = new(config)
+
+type config struct {
+	// Exporter is none, otlp, stdout or file.
+	Exporter     string  `env:"TRACING_EXPORTER,default=none" json:",omitempty"`
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT" json:",omitempty"`
+	File         string  `env:"TRACING_FILE,default=./assets/traces.json" json:",omitempty"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO,default=1" json:",omitempty"`
+	ServiceName  string  `env:"TRACING_SERVICE_NAME,default=backend-challenge" json:",omitempty"`
+}
+
+func (c *config) Validate() error {
+	var errs []error
+	switch c.Exporter {
+	case "", "none", "otlp", "stdout", "file":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER %q is not none, otlp, stdout or file", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO %v is not between 0 and 1", c.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
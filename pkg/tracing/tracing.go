@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "backend-challenge"
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter is
+// "none", a tracer provider. The returned function flushes and stops it.
+func Setup(ctx context.Context, cfg *config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil {
+			return nil, err
+		}
+		file, ferr := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if ferr != nil {
+			return nil, ferr
+		}
+		closer = file
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so outgoing requests get a client span and carry the
+// traceparent and X-Request-ID headers.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLFull(req.URL.Redacted())),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if requestID, ok := ctx.Value(entities.RequestId).(string); ok && requestID != "" {
+		req.Header.Set(entities.RequestIDHeader, requestID)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,36 @@
+package routers
+
+import (
+	handlers "backend-challenge/adapters/http"
+	mongo "backend-challenge/adapters/mongo"
+	"backend-challenge/configs"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/usecases"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetupAdminRoutes mounts the diagnostics of the admin listener next to the
+// metrics and pprof added by SetAdmin. Nothing here is on the public app.
+func SetupAdminRoutes(cfg *configs.Setting) {
+	if cfg.Admin == nil {
+		return
+	}
+	repository := mongo.NewMongoRepository(cfg.DBMongo)
+	httpLogLevel := usecases.NewHttpLogLevel(validator.New(), logging.Levels, repository)
+	httpHealth := usecases.NewHttpHealth(cfg.Health)
+
+	admin := cfg.Admin
+	admin.Get("/log-levels", httpLogLevel.GetAll)
+	admin.Put("/log-levels", httpLogLevel.Set)
+	admin.Delete("/log-levels", httpLogLevel.Reset)
+	admin.Get("/livez", httpHealth.Live)
+	admin.Get("/health", httpHealth.Health)
+	admin.Get("/jobs", httpHealth.Jobs)
+
+	admin.Use(func(c *fiber.Ctx) error {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorCode: "ER404", ErrorMessage: "ไม่พบ Path", StatusCode: 404})
+	})
+}
@@ -3,9 +3,12 @@ package routers
 import (
 	handlers "backend-challenge/adapters/http"
 	mongo "backend-challenge/adapters/mongo"
+	oidc "backend-challenge/adapters/oidc"
 	"backend-challenge/configs"
 	"backend-challenge/entities"
 	"backend-challenge/middlewares"
+	"backend-challenge/pkg/certs"
+	"backend-challenge/pkg/logging"
 	"backend-challenge/usecases"
 
 	"github.com/go-playground/validator/v10"
@@ -16,26 +19,146 @@ func SetupRoutes(cfg *configs.Setting) {
 	validate := validator.New()
 	prefix := cfg.App.Group(configs.App.Prefix)
 
-	repository := mongo.NewMongoRepository(cfg.DBMongo.DB)
-	httpUser := usecases.NewHttpUser(validate, repository)
+	repository := mongo.NewMongoRepository(cfg.DBMongo)
+	options := []usecases.Option{
+		usecases.WithEmailVerification(repository, cfg.Mailer, usecases.VerificationConfig{
+			TTL:             configs.Auth.VerificationTTL,
+			RequireVerified: configs.Auth.RequireVerifiedEmail,
+			LinkURL:         configs.Auth.VerifyEmailURL,
+		}),
+		usecases.WithPasswordReset(repository, cfg.Mailer, usecases.PasswordResetConfig{
+			TTL:     configs.Auth.PasswordResetTTL,
+			LinkURL: configs.Auth.ResetPasswordURL,
+		}),
+		usecases.WithMFA(repository, usecases.MFAConfig{
+			Issuer:       configs.Auth.MFAIssuer,
+			ChallengeTTL: configs.Auth.MFAChallengeTTL,
+		}),
+		usecases.WithSessions(repository),
+		usecases.WithAccount(repository, usecases.AccountConfig{
+			DeletionGracePeriod: configs.Auth.DeletionGracePeriod,
+		}),
+	}
+	if configs.OIDC.Enabled() {
+		provider := oidc.NewProvider(oidc.Config{
+			Issuer:       configs.OIDC.Issuer,
+			ClientID:     configs.OIDC.ClientID,
+			ClientSecret: configs.OIDC.ClientSecret,
+			RedirectURL:  configs.OIDC.RedirectURL,
+			Scopes:       configs.OIDC.Scopes,
+		})
+		options = append(options, usecases.WithOIDC(provider, repository, usecases.OIDCConfig{
+			ProviderName: configs.OIDC.ProviderName,
+			StateTTL:     configs.OIDC.StateTTL,
+			SecureCookie: configs.Auth.CookieSecure,
+		}))
+	}
+	if configs.Auth.CookieMode {
+		options = append(options, usecases.WithCookieSession(usecases.CookieConfig{
+			Name:     configs.Auth.CookieName,
+			CSRFName: configs.Auth.CSRFCookieName,
+			Domain:   configs.Auth.CookieDomain,
+			Secure:   configs.Auth.CookieSecure,
+			SameSite: configs.Auth.CookieSameSite,
+		}))
+	}
+	httpUser := usecases.NewHttpUser(validate, repository, options...)
+	httpAPIKey := usecases.NewHttpAPIKey(validate, repository)
+	httpLogLevel := usecases.NewHttpLogLevel(validate, logging.Levels, repository)
+	httpOAuth := usecases.NewHttpOAuth(validate, repository, usecases.OAuthConfig{
+		AccessTokenTTL:  configs.OAuth.AccessTokenTTL,
+		RefreshTokenTTL: configs.OAuth.RefreshTokenTTL,
+		CodeTTL:         configs.OAuth.CodeTTL,
+	})
+	jwtConfig := middlewares.JWTConfig{
+		Revocations:          repository,
+		Sessions:             repository,
+		SessionTouchInterval: configs.Auth.SessionTouchInterval,
+		APIKeys:              repository,
+		AccessTokens:         repository,
+	}
+	if configs.Auth.CookieMode {
+		jwtConfig.Cookie = &middlewares.CookieAuth{
+			Name:       configs.Auth.CookieName,
+			CSRFCookie: configs.Auth.CSRFCookieName,
+			CSRFHeader: configs.Auth.CSRFHeader,
+		}
+	}
+	if certs.C.ClientCAFile != "" {
+		jwtConfig.ClientCerts = &middlewares.ClientCertAuth{Scopes: certs.C.ClientScopes}
+	}
+
 	//group auth
 	auth := prefix.Group("/auth")
 	auth.Post("/register", httpUser.Create)
 	auth.Post("/login", httpUser.Login)
+	auth.Post("/verify-email", httpUser.VerifyEmail)
+	auth.Post("/resend-verification", httpUser.ResendVerification)
+	auth.Post("/forgot-password", httpUser.ForgotPassword)
+	auth.Post("/reset-password", httpUser.ResetPassword)
+	auth.Post("/mfa/verify", httpUser.VerifyMFA)
+	auth.Post("/logout", middlewares.JWTMiddleware(jwtConfig), httpUser.Logout)
+	auth.Get("/oidc/login", httpUser.OIDCLogin)
+	auth.Get("/oidc/callback", httpUser.OIDCCallback)
+
+	// tokens delegated to OAuth clients cannot manage the account or approve other clients
+	firstParty := middlewares.RequireFirstParty()
+
+	//group oauth
+	oauth := prefix.Group("/oauth")
+	oauth.Post("/authorize", middlewares.JWTMiddleware(jwtConfig), firstParty, httpOAuth.Authorize)
+	oauth.Post("/token", httpOAuth.Token)
+	oauth.Post("/introspect", httpOAuth.Introspect)
+	oauth.Post("/revoke", httpOAuth.Revoke)
 
 	// //group protected with jwt
 	users := prefix.Group("/users")
-	users.Use(middlewares.JWTMiddleware())
-	users.Get("/", httpUser.GetAll)
-	users.Get("/:id", httpUser.Get)
-	users.Patch("/:id", httpUser.Update)
-	users.Delete("/:id", httpUser.Delete)
-
-	prefix.Get("/healthcheck", func(c *fiber.Ctx) error {
-		return handlers.Response(c, entities.Response{Status: "OK", Message: "Healthy"}, map[string]interface{}{"function": "Healthcheck"})
-	})
+	users.Use(middlewares.JWTMiddleware(jwtConfig))
+	users.Get("/me", middlewares.RequireScope(entities.ScopeUsersRead), httpUser.GetMe)
+	users.Patch("/me", firstParty, httpUser.UpdateMe)
+	users.Delete("/me", firstParty, httpUser.DeleteMe)
+	users.Post("/me/cancel-deletion", firstParty, httpUser.CancelDeleteMe)
+	users.Post("/me/password", firstParty, httpUser.ChangePassword)
+	users.Post("/me/mfa/enroll", firstParty, httpUser.EnrollMFA)
+	users.Post("/me/mfa/confirm", firstParty, httpUser.ConfirmMFA)
+	users.Get("/me/sessions", firstParty, httpUser.ListMySessions)
+	users.Delete("/me/sessions/:id", firstParty, httpUser.RevokeMySession)
+	users.Get("/me/consents", firstParty, httpOAuth.ListMyConsents)
+	users.Delete("/me/consents/:clientId", firstParty, httpOAuth.RevokeMyConsent)
+	// other accounts are for admins and scoped services; users manage their own under /me
+	manageUsers := middlewares.RequireUserRole(repository, entities.RoleAdmin)
+	users.Get("/", middlewares.RequireScope(entities.ScopeUsersRead), manageUsers, httpUser.GetAll)
+	users.Get("/:id", middlewares.RequireScope(entities.ScopeUsersRead), manageUsers, httpUser.Get)
+	users.Patch("/:id", middlewares.RequireScope(entities.ScopeUsersWrite), manageUsers, httpUser.Update)
+	users.Delete("/:id", middlewares.RequireScope(entities.ScopeUsersWrite), manageUsers, httpUser.Delete)
+
+	//group admin
+	admin := prefix.Group("/admin")
+	admin.Use(middlewares.JWTMiddleware(jwtConfig), middlewares.RequireRole(repository, entities.RoleAdmin))
+	admin.Get("/users/:id/sessions", httpUser.ListUserSessions)
+	admin.Delete("/users/:id/sessions", httpUser.RevokeUserSessions)
+	admin.Delete("/users/:id/sessions/:sessionId", httpUser.RevokeUserSession)
+	admin.Post("/api-keys", httpAPIKey.Create)
+	admin.Get("/api-keys", httpAPIKey.GetAll)
+	admin.Get("/api-keys/:id", httpAPIKey.Get)
+	admin.Patch("/api-keys/:id", httpAPIKey.Update)
+	admin.Delete("/api-keys/:id", httpAPIKey.Delete)
+	admin.Post("/oauth-clients", httpOAuth.CreateClient)
+	admin.Get("/oauth-clients", httpOAuth.GetClients)
+	admin.Get("/oauth-clients/:clientId", httpOAuth.GetClient)
+	admin.Delete("/oauth-clients/:clientId", httpOAuth.DeleteClient)
+	admin.Get("/log-levels", httpLogLevel.GetAll)
+	admin.Put("/log-levels", httpLogLevel.Set)
+	admin.Delete("/log-levels", httpLogLevel.Reset)
+
+	httpHealth := usecases.NewHttpHealth(cfg.Health)
+	prefix.Get("/livez", httpHealth.Live)
+	prefix.Get("/readyz", httpHealth.Ready)
+	prefix.Get("/healthcheck", httpHealth.Ready)
 
 	prefix.Use(func(c *fiber.Ctx) error {
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorCode: "ER404", ErrorMessage: "ไม่พบ Path", StatusCode: 404})
 	})
+
+	SetupAdminRoutes(cfg)
 }
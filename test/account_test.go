@@ -0,0 +1,136 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAccountRepo struct {
+	mock.Mock
+}
+
+func (m *mockAccountRepo) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	args := m.Called(event, ctx)
+	return args.Error(0)
+}
+func (m *mockAccountRepo) UpdateProfile(userId string, name string, email string, ctx context.Context) error {
+	args := m.Called(userId, name, email, ctx)
+	return args.Error(0)
+}
+func (m *mockAccountRepo) ScheduleDeletion(userId string, at time.Time, ctx context.Context) error {
+	args := m.Called(userId, at, ctx)
+	return args.Error(0)
+}
+func (m *mockAccountRepo) CancelDeletion(userId string, ctx context.Context) error {
+	args := m.Called(userId, ctx)
+	return args.Error(0)
+}
+func (m *mockAccountRepo) RevokeUserSessions(userId string, ctx context.Context) error {
+	args := m.Called(userId, ctx)
+	return args.Error(0)
+}
+
+func setupAccountApp(repo *mockUserRepo, accountRepo *mockAccountRepo, grace time.Duration) *fiber.App {
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithAccount(accountRepo, usecases.AccountConfig{DeletionGracePeriod: grace}))
+	app := fiber.New()
+	me := app.Group("/users/me", withUser("abc123"))
+	me.Get("/", h.GetMe)
+	me.Patch("/", h.UpdateMe)
+	me.Delete("/", h.DeleteMe)
+	me.Post("/cancel-deletion", h.CancelDeleteMe)
+	return app
+}
+
+func TestGetMe(t *testing.T) {
+	repo := new(mockUserRepo)
+	app := setupAccountApp(repo, new(mockAccountRepo), 0)
+
+	repo.On("GetUser", "abc123", mock.Anything).Return(entities.User{Name: "Tee"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	repo.AssertExpectations(t)
+}
+
+func TestUpdateMe_EmailChangeRequiresPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	accountRepo := new(mockAccountRepo)
+	app := setupAccountApp(repo, accountRepo, 0)
+
+	repo.On("GetUser", "abc123", mock.Anything).Return(entities.User{Email: "old@b.com", Password: utils.Hash("secret-password")}, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"email":"new@b.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 400, resp.StatusCode)
+	accountRepo.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	repo.On("CheckDuplicateUser", "new@b.com", mock.Anything).Return(nil)
+	accountRepo.On("UpdateProfile", "abc123", "", "new@b.com", mock.Anything).Return(nil)
+	accountRepo.On("RecordAudit", mock.MatchedBy(func(e entities.AuditEvent) bool { return e.Action == entities.AuditEmailChanged }), mock.Anything).Return(nil)
+
+	req = httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"email":"new@b.com","currentPassword":"secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	accountRepo.AssertExpectations(t)
+}
+
+func TestUpdateMe_NameAndEmailInOneUpdate(t *testing.T) {
+	repo := new(mockUserRepo)
+	accountRepo := new(mockAccountRepo)
+	app := setupAccountApp(repo, accountRepo, 0)
+
+	repo.On("GetUser", "abc123", mock.Anything).Return(entities.User{Email: "old@b.com", Password: utils.Hash("secret-password")}, nil)
+	repo.On("CheckDuplicateUser", "new@b.com", mock.Anything).Return(nil)
+	// another account took the address between the check and the update
+	accountRepo.On("UpdateProfile", "abc123", "New Name", "new@b.com", mock.Anything).Return(errors.New("email already exists")).Once()
+
+	req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"name":"New Name","email":"new@b.com","currentPassword":"secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 400, resp.StatusCode)
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+	accountRepo.AssertNotCalled(t, "RecordAudit", mock.Anything, mock.Anything)
+
+	accountRepo.On("UpdateProfile", "abc123", "New Name", "", mock.Anything).Return(nil)
+	req = httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(`{"name":"New Name"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	accountRepo.AssertExpectations(t)
+}
+
+func TestDeleteMe_GracePeriod(t *testing.T) {
+	repo := new(mockUserRepo)
+	accountRepo := new(mockAccountRepo)
+	app := setupAccountApp(repo, accountRepo, 24*time.Hour)
+
+	accountRepo.On("ScheduleDeletion", "abc123", mock.AnythingOfType("time.Time"), mock.Anything).Return(nil)
+	accountRepo.On("CancelDeletion", "abc123", mock.Anything).Return(nil)
+	accountRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/me", nil)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	repo.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+
+	req = httptest.NewRequest(http.MethodPost, "/users/me/cancel-deletion", nil)
+	resp, _ = app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	accountRepo.AssertExpectations(t)
+}
@@ -0,0 +1,179 @@
+package user_test
+
+import (
+	"backend-challenge/configs"
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/health"
+	"backend-challenge/pkg/metrics"
+	"backend-challenge/pkg/shutdown"
+	"backend-challenge/routers"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func freePort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func waitDial(t *testing.T, network, addr string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial(network, addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("nothing listens on %s %s", network, addr)
+}
+
+func TestAdminListener_UnixSocket(t *testing.T) {
+	withConfigRestored(t)
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	configs.App.Port = freePort(t)
+	configs.App.ShutdownDelay = 0
+	configs.App.ShutdownTimeout = time.Second
+	configs.Admin.Addr = "unix:" + socket
+	configs.Admin.SocketMode = "0600"
+	configs.Admin.Token = "admin-token"
+	metrics.M.Enabled, metrics.M.Path = true, "/metrics"
+
+	ctx := context.Background()
+	app := configs.NewApp(zap.NewNop().Sugar())
+	app.App = fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Health = health.NewRegistry(time.Second)
+	app.Workers = shutdown.NewWorkers(ctx)
+	app.SetAdmin()
+	routers.SetupAdminRoutes(app)
+
+	errChan := app.RunApp(ctx)
+	app.Go("user_count_logger", func(ctx context.Context) { <-ctx.Done() })
+	waitDial(t, "unix", socket)
+	waitDial(t, "tcp", "127.0.0.1:"+configs.App.Port)
+
+	info, err := os.Stat(socket)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	get := func(path, token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, "http://admin"+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/jobs", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, get("/jobs", "wrong").StatusCode)
+	for _, path := range []string{"/metrics", "/debug/pprof/", "/log-levels", "/livez"} {
+		assert.Equal(t, http.StatusOK, get(path, "admin-token").StatusCode, path)
+	}
+	assert.Equal(t, http.StatusNotFound, get("/users", "admin-token").StatusCode)
+
+	var jobs struct {
+		Data []health.WorkerStatus `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(get("/jobs", "admin-token").Body).Decode(&jobs))
+	if assert.Len(t, jobs.Data, 1) {
+		assert.Equal(t, "user_count_logger", jobs.Data[0].Name)
+		assert.True(t, jobs.Data[0].Running)
+		assert.NotNil(t, jobs.Data[0].StartedAt)
+	}
+
+	var report struct {
+		Data health.Report `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(get("/health", "admin-token").Body).Decode(&report))
+	assert.Equal(t, health.StatusUp, report.Data.Status)
+
+	// the diagnostics are not on the public listener
+	resp, err := http.Get("http://127.0.0.1:" + configs.App.Port + "/metrics")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	assert.NoError(t, app.StopApp(ctx))
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+	select {
+	case err := <-errChan:
+		t.Fatalf("listener failed: %v", err)
+	default:
+	}
+	assert.False(t, app.Health.Workers()[0].Running)
+}
+
+func TestAdminMiddleware_SetsAdminPrincipal(t *testing.T) {
+	admin := fiber.New()
+	admin.Use(middlewares.AdminMiddleware(""))
+	admin.Get("/whoami", func(c *fiber.Ctx) error {
+		principal, _ := entities.PrincipalFromContext(c.UserContext())
+		return c.JSON(principal)
+	})
+	resp, err := admin.Test(httptest.NewRequest(fiber.MethodGet, "/whoami", nil))
+	assert.NoError(t, err)
+	var principal entities.Principal
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+	assert.Equal(t, entities.Principal{Type: entities.PrincipalService, ID: "admin", Name: "admin listener", Scopes: []string{entities.ScopeAdmin}}, principal)
+}
+
+func TestAdminConfig_MetricsAddrFallback(t *testing.T) {
+	withConfigRestored(t)
+	configs.Admin.Addr, metrics.M.Addr = "", ":9090"
+	assert.Equal(t, ":9090", configs.Admin.Address())
+	configs.Admin.Addr = "unix:/run/app/admin.sock"
+	assert.Equal(t, "unix:/run/app/admin.sock", configs.Admin.Address())
+
+	configs.Admin.SocketMode = "rw"
+	assert.EqualError(t, configs.Admin.Validate(), `ADMIN_SOCKET_MODE "rw" is not an octal file mode`)
+}
+
+func TestAdminConfig_TokenRequiredOffLoopback(t *testing.T) {
+	withConfigRestored(t)
+	configs.Admin.Token, configs.Admin.SocketMode = "", "0660"
+	for _, addr := range []string{":9090", "0.0.0.0:9090", "10.0.0.5:9090", "[::]:9090"} {
+		configs.Admin.Addr = addr
+		assert.EqualError(t, configs.Admin.Validate(), `ADMIN_TOKEN is required when ADMIN_ADDR "`+addr+`" is not a loopback address or a unix socket`)
+	}
+	for _, addr := range []string{"", "127.0.0.1:9090", "localhost:9090", "[::1]:9090", "unix:/run/app/admin.sock"} {
+		configs.Admin.Addr = addr
+		assert.NoError(t, configs.Admin.Validate(), addr)
+	}
+	configs.Admin.Addr, configs.Admin.Token = ":9090", "admin-token"
+	assert.NoError(t, configs.Admin.Validate())
+
+	// the older METRICS_ADDR is held to the same rule
+	_, err := configs.Loader{
+		Args: []string{"--config", writeFile(t, "app.yaml", yamlConfig)},
+		Env:  envconfig.MapLookuper(map[string]string{"METRICS_ADDR": ":9090"}),
+	}.Load(context.Background())
+	var configErr *configs.ConfigError
+	if assert.ErrorAs(t, err, &configErr) {
+		assert.Contains(t, configErr.Problems, `ADMIN_TOKEN is required when METRICS_ADDR ":9090" is not a loopback address or a unix socket`)
+	}
+}
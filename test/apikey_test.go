@@ -0,0 +1,168 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mockAPIKeyStore struct {
+	mock.Mock
+}
+
+func (m *mockAPIKeyStore) GetAPIKeyByPrefix(prefix string, ctx context.Context) (entities.APIKey, error) {
+	args := m.Called(prefix, ctx)
+	return args.Get(0).(entities.APIKey), args.Error(1)
+}
+func (m *mockAPIKeyStore) TouchAPIKey(keyId string, interval time.Duration, ctx context.Context) error {
+	args := m.Called(keyId, interval, ctx)
+	return args.Error(0)
+}
+
+func setupAPIKeyApp(store *mockAPIKeyStore) *fiber.App {
+	app := fiber.New()
+	app.Use(middlewares.JWTMiddleware(middlewares.JWTConfig{APIKeys: store}))
+	app.Get("/users", middlewares.RequireScope(entities.ScopeUsersRead), func(c *fiber.Ctx) error {
+		principal, _ := entities.PrincipalFromContext(c.UserContext())
+		return c.SendString(principal.Type)
+	})
+	app.Delete("/users/:id", middlewares.RequireScope(entities.ScopeUsersWrite), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+	return app
+}
+
+func TestAPIKey_ScopedAccess(t *testing.T) {
+	store := new(mockAPIKeyStore)
+	app := setupAPIKeyApp(store)
+
+	key, prefix, hash, err := utils.GenerateAPIKey()
+	assert.NoError(t, err)
+	stored := entities.APIKey{ID: primitive.NewObjectID(), Prefix: prefix, Hash: hash, Scopes: []string{entities.ScopeUsersRead}}
+	store.On("GetAPIKeyByPrefix", prefix, mock.Anything).Return(stored, nil)
+	store.On("TouchAPIKey", stored.ID.Hex(), mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(middlewares.HeaderAPIKey, key)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	req = httptest.NewRequest(http.MethodDelete, "/users/abc123", nil)
+	req.Header.Set(middlewares.HeaderAPIKey, key)
+	resp, _ = app.Test(req)
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+func TestAPIKey_Rejected(t *testing.T) {
+	store := new(mockAPIKeyStore)
+	app := setupAPIKeyApp(store)
+
+	key, prefix, hash, _ := utils.GenerateAPIKey()
+	expired := time.Now().Add(-time.Minute)
+	store.On("GetAPIKeyByPrefix", prefix, mock.Anything).Return(entities.APIKey{Prefix: prefix, Hash: hash, ExpiresAt: &expired}, nil)
+	store.On("GetAPIKeyByPrefix", mock.Anything, mock.Anything).Return(entities.APIKey{}, mongo.ErrNoDocuments)
+
+	for _, k := range []string{key, "bck_" + prefix + "_wrong-secret", "not-a-key"} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(middlewares.HeaderAPIKey, k)
+		resp, _ := app.Test(req)
+		assert.Equal(t, 401, resp.StatusCode, k)
+	}
+	store.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestJWT_UserPrincipal(t *testing.T) {
+	app := setupAPIKeyApp(new(mockAPIKeyStore))
+
+	token, _ := utils.GenerateToken("user-1", time.Hour)
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+type roleLookup map[string]string
+
+func (r roleLookup) GetUserRole(userId string, ctx context.Context) (string, error) {
+	role, ok := r[userId]
+	if !ok {
+		return "", errors.New("user not found")
+	}
+	return role, nil
+}
+
+func withPrincipal(principal entities.Principal) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(context.WithValue(c.UserContext(), entities.PrincipalKey, principal))
+		return c.Next()
+	}
+}
+
+func TestUpdateUser_OnlyAdminsManageOtherAccounts(t *testing.T) {
+	roles := roleLookup{"userA": entities.RoleUser, "userB": entities.RoleUser, "admin1": entities.RoleAdmin}
+	patch := func(principal entities.Principal) (*mockUserRepo, int) {
+		repo := new(mockUserRepo)
+		h := usecases.NewHttpUser(validator.New(), repo)
+		app := fiber.New()
+		app.Patch("/users/:id", withPrincipal(principal), middlewares.RequireScope(entities.ScopeUsersWrite), middlewares.RequireUserRole(roles, entities.RoleAdmin), h.Update)
+		repo.On("CheckDuplicateUser", "attacker@b.com", mock.Anything).Return(nil)
+		repo.On("UpdateUser", "userA", mock.AnythingOfType("entities.UpdateUserRequest"), mock.Anything).Return(nil)
+
+		req := httptest.NewRequest(http.MethodPatch, "/users/userA", strings.NewReader(`{"email":"attacker@b.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return repo, resp.StatusCode
+	}
+
+	// first-party user tokens carry no scopes, so the scope check alone let them through
+	repo, status := patch(entities.Principal{Type: entities.PrincipalUser, ID: "userB"})
+	assert.Equal(t, 403, status)
+	repo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+
+	repo, status = patch(entities.Principal{Type: entities.PrincipalUser, ID: "admin1"})
+	assert.Equal(t, 200, status)
+	repo.AssertCalled(t, "UpdateUser", "userA", mock.Anything, mock.Anything)
+
+	_, status = patch(entities.Principal{Type: entities.PrincipalService, ID: "key1", Scopes: []string{entities.ScopeUsersWrite}})
+	assert.Equal(t, 200, status)
+	_, status = patch(entities.Principal{Type: entities.PrincipalService, ID: "key2", Scopes: []string{entities.ScopeUsersRead}})
+	assert.Equal(t, 403, status)
+}
+
+func TestUserResponses_LeaveOutPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	h := usecases.NewHttpUser(validator.New(), repo)
+	app := fiber.New()
+	app.Get("/users", h.GetAll)
+	app.Get("/users/:id", h.Get)
+
+	user := entities.User{Name: "Tee", Email: "a@b.com", Password: utils.Hash("secret-password")}
+	repo.On("GetUserAll", mock.Anything).Return([]entities.User{user}, nil)
+	repo.On("GetUser", "abc123", mock.Anything).Return(user, nil)
+
+	// a users:read key can list every account
+	for _, path := range []string{"/users", "/users/abc123"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, 200, resp.StatusCode, path)
+		assert.Contains(t, string(body), `"email":"a@b.com"`, path)
+		assert.NotContains(t, string(body), `"password"`, path)
+	}
+}
@@ -0,0 +1,94 @@
+package user_test
+
+import (
+	"backend-challenge/configs"
+	"backend-challenge/middlewares"
+	"backend-challenge/usecases"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var cookieConfig = usecases.CookieConfig{Name: "access_token", CSRFName: "csrf_token", Secure: true, SameSite: "Strict"}
+
+func setupCookieApp(repo *mockUserRepo) *fiber.App {
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithCookieSession(cookieConfig))
+	app := fiber.New()
+	app.Post("/auth/login", h.Login)
+
+	protected := middlewares.JWTMiddleware(middlewares.JWTConfig{Cookie: &middlewares.CookieAuth{
+		Name:       cookieConfig.Name,
+		CSRFCookie: cookieConfig.CSRFName,
+		CSRFHeader: "X-CSRF-Token",
+	}})
+	app.Get("/users/me", protected, func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	app.Post("/auth/logout", protected, h.Logout)
+	return app
+}
+
+func TestCookieLoginAndCSRF(t *testing.T) {
+	repo := new(mockUserRepo)
+	app := setupCookieApp(repo)
+	repo.On("Login", mock.AnythingOfType("entities.Login"), mock.Anything).Return("userid123", nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"a@b.com","password":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data map[string]string `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.Empty(t, body.Data["accessToken"])
+	csrf := body.Data["csrfToken"]
+	assert.NotEmpty(t, csrf)
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	assert.True(t, cookies["access_token"].HttpOnly)
+	assert.True(t, cookies["access_token"].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies["access_token"].SameSite)
+	assert.False(t, cookies["csrf_token"].HttpOnly)
+	assert.Equal(t, csrf, cookies["csrf_token"].Value)
+
+	send := func(method, path, csrfHeader string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(cookies["access_token"])
+		req.AddCookie(cookies["csrf_token"])
+		if csrfHeader != "" {
+			req.Header.Set("X-CSRF-Token", csrfHeader)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// safe methods need no CSRF header
+	assert.Equal(t, 200, send(http.MethodGet, "/users/me", ""))
+	assert.Equal(t, 403, send(http.MethodPost, "/auth/logout", ""))
+	assert.Equal(t, 403, send(http.MethodPost, "/auth/logout", "forged"))
+	assert.Equal(t, 200, send(http.MethodPost, "/auth/logout", csrf))
+}
+
+func TestCORSConfigRejectsWildcardWithCredentials(t *testing.T) {
+	previous := *configs.CORS
+	t.Cleanup(func() { *configs.CORS = previous })
+
+	configs.CORS.AllowOrigins = []string{"*"}
+	configs.CORS.AllowCredentials = true
+	assert.Error(t, configs.CORS.Validate())
+
+	configs.CORS.AllowOrigins = []string{"https://app.example.com"}
+	assert.NoError(t, configs.CORS.Validate())
+}
@@ -0,0 +1,110 @@
+package user_test
+
+import (
+	"backend-challenge/pkg/health"
+	"backend-challenge/usecases"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHealthApp(registry *health.Registry) *fiber.App {
+	h := usecases.NewHttpHealth(registry)
+	app := fiber.New()
+	app.Get("/livez", h.Live)
+	app.Get("/readyz", h.Ready)
+	app.Get("/health", h.Health)
+	return app
+}
+
+func getReport(t *testing.T, app *fiber.App) (int, health.Report) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.NoError(t, err)
+	var body struct {
+		Data health.Report `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body.Data
+}
+
+func TestReadyz_ReportsEachCheck(t *testing.T) {
+	registry := health.NewRegistry(50 * time.Millisecond)
+	registry.Register("mongo", func(ctx context.Context) error { return nil })
+	app := setupHealthApp(registry)
+
+	status, report := getReport(t, app)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, health.StatusUp, report.Status)
+	if assert.Len(t, report.Checks, 1) {
+		assert.Equal(t, "mongo", report.Checks[0].Name)
+		assert.NotEmpty(t, report.Checks[0].Latency)
+	}
+
+	registry.Register("migrations", func(ctx context.Context) error { return errors.New("1 migration(s) pending") })
+	registry.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	status, report = getReport(t, app)
+	assert.Equal(t, 503, status)
+	assert.Equal(t, health.StatusDown, report.Status)
+	byName := map[string]health.CheckResult{}
+	for _, check := range report.Checks {
+		byName[check.Name] = check
+	}
+	assert.Equal(t, health.StatusUp, byName["mongo"].Status)
+	assert.Equal(t, "1 migration(s) pending", byName["migrations"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), byName["slow"].Error)
+}
+
+func TestReadyz_WorkerAndDraining(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	worker := registry.Worker("user_count_logger")
+	app := setupHealthApp(registry)
+
+	status, _ := getReport(t, app)
+	assert.Equal(t, 503, status)
+
+	worker.Start()
+	status, _ = getReport(t, app)
+	assert.Equal(t, 200, status)
+
+	// shutdown started: not ready, but still alive
+	registry.SetDraining()
+	status, report := getReport(t, app)
+	assert.Equal(t, 503, status)
+	assert.True(t, report.Draining)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestReadyz_OnlyTellsTheStatus(t *testing.T) {
+	registry := health.NewRegistry(time.Second)
+	registry.Register("mongo", func(ctx context.Context) error { return errors.New("dial tcp 10.0.3.7:27017: connection refused") })
+	app := setupHealthApp(registry)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, map[string]interface{}{"status": health.StatusDown}, body.Data)
+
+	// the details stay on the admin listener
+	status, report := getReport(t, app)
+	assert.Equal(t, 503, status)
+	if assert.Len(t, report.Checks, 1) {
+		assert.Contains(t, report.Checks[0].Error, "connection refused")
+	}
+}
@@ -0,0 +1,101 @@
+package user_test
+
+import (
+	"backend-challenge/pkg/logging"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain keeps log files of the whole package out of the source tree.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "backend-challenge-logs")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("LOG_PATH", dir)
+	logging.L.LogPath = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func touch(t *testing.T, path string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o644))
+}
+
+func TestDailyWriter_RollsAtLocalMidnight(t *testing.T) {
+	root := t.TempDir()
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	assert.NoError(t, err)
+
+	touch(t, filepath.Join(root, "2026_01", "10", "info.json"))
+	touch(t, filepath.Join(root, "2026_01", "10", "error.json"))
+	touch(t, filepath.Join(root, "2026_02", "27", "info.json"))
+	touch(t, filepath.Join(root, "2026_02", "28", "info.json"))
+
+	now := time.Date(2026, 3, 1, 16, 59, 0, 0, time.UTC) // 23:59 in Bangkok
+	w := &logging.DailyWriter{
+		Root:     root,
+		Name:     "info.json",
+		Location: bangkok,
+		MaxAge:   30 * 24 * time.Hour,
+		MaxDays:  3,
+		Compress: true,
+		Now:      func() time.Time { return now },
+	}
+
+	_, err = w.Write([]byte("before midnight\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	now = now.Add(2 * time.Minute) // 00:01 the next day in Bangkok, still March 1st in UTC
+	_, err = w.Write([]byte("after midnight\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(root, "2026_03", "02", "info.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "after midnight\n", string(data))
+
+	// past days are compressed
+	assert.FileExists(t, filepath.Join(root, "2026_03", "01", "info.json.gz"))
+	assert.NoFileExists(t, filepath.Join(root, "2026_03", "01", "info.json"))
+	assert.FileExists(t, filepath.Join(root, "2026_02", "28", "info.json.gz"))
+
+	// beyond MaxDays
+	assert.NoDirExists(t, filepath.Join(root, "2026_02", "27"))
+
+	// older than MaxAge, but only this writer's files are removed
+	assert.NoFileExists(t, filepath.Join(root, "2026_01", "10", "info.json"))
+	assert.FileExists(t, filepath.Join(root, "2026_01", "10", "error.json"))
+}
+
+func TestNewLogger_HonorsLogPath(t *testing.T) {
+	previous := *logging.L
+	t.Cleanup(func() { *logging.L = previous })
+
+	root := t.TempDir()
+	logging.L.LogPath = root
+	logging.L.LogMode = "production"
+	logging.L.LogTimezone = "UTC"
+	logging.L.LogLevel = "INFO"
+
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	assert.NoError(t, err)
+	logger.Info("hello")
+	logger.Error("boom")
+	assert.NoError(t, closeLogs())
+
+	day := time.Now().UTC().Format("2006_01/02")
+	assert.FileExists(t, filepath.Join(root, filepath.FromSlash(day), "info.json"))
+	assert.FileExists(t, filepath.Join(root, filepath.FromSlash(day), "error.json"))
+
+	logging.L.LogTimezone = "Mars/Olympus_Mons"
+	_, _, err = logging.NewLogger(logging.L)
+	assert.Error(t, err)
+}
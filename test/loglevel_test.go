@@ -0,0 +1,114 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/usecases"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelRegistry_NamedOverridesAndTTL(t *testing.T) {
+	levels := logging.NewLevelRegistry(zapcore.InfoLevel)
+
+	levels.Set("backend-chellenge", zapcore.DebugLevel, 0)
+	assert.Equal(t, zapcore.DebugLevel, levels.Level("backend-chellenge.set environment"))
+	assert.Equal(t, zapcore.InfoLevel, levels.Level("other"))
+
+	levels.Set("", zapcore.ErrorLevel, 30*time.Millisecond)
+	assert.Equal(t, zapcore.ErrorLevel, levels.Level("other"))
+	assert.NotNil(t, levels.List()[0].ExpiresAt)
+	assert.Eventually(t, func() bool { return levels.Level("other") == zapcore.InfoLevel }, time.Second, 5*time.Millisecond)
+
+	// a later Set without TTL is not undone by the earlier timer
+	levels.Set("set environment", zapcore.DebugLevel, 20*time.Millisecond)
+	levels.Set("set environment", zapcore.WarnLevel, 0)
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, zapcore.WarnLevel, levels.Level("set environment"))
+
+	levels.Reset("set environment")
+	assert.Equal(t, zapcore.InfoLevel, levels.Level("set environment"))
+}
+
+func TestNewLogger_AppliesLevelsToFiles(t *testing.T) {
+	previous := *logging.L
+	t.Cleanup(func() {
+		*logging.L = previous
+		logging.Levels.Reset("quiet")
+		logging.Levels.Reset("chatty")
+	})
+
+	root := t.TempDir()
+	logging.L.LogPath = root
+	logging.L.LogMode = "production"
+	logging.L.LogTimezone = "UTC"
+	logging.L.LogLevel = "INFO"
+
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	assert.NoError(t, err)
+	logging.Levels.Set("quiet", zapcore.WarnLevel, 0)
+	logging.Levels.Set("chatty", zapcore.DebugLevel, 0)
+
+	logger.Debug("root debug")
+	logger.Named("quiet").Info("quiet info")
+	logger.Named("chatty").Debug("chatty debug")
+	assert.NoError(t, closeLogs())
+
+	day := time.Now().UTC().Format("2006_01/02")
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(day), "info.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "root debug")
+	assert.NotContains(t, string(data), "quiet info")
+	assert.Contains(t, string(data), "chatty debug")
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	levels := logging.NewLevelRegistry(zapcore.InfoLevel)
+	audit := new(mockPasswordRepo)
+	audit.On("RecordAudit", mock.MatchedBy(func(e entities.AuditEvent) bool {
+		return e.Action == entities.AuditLogLevelChanged
+	}), mock.Anything).Return(nil)
+
+	h := usecases.NewHttpLogLevel(validator.New(), levels, audit)
+	app := fiber.New()
+	app.Get("/admin/log-levels", h.GetAll)
+	app.Put("/admin/log-levels", h.Set)
+
+	put := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-levels", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 400, put(`{"logger":"set environment","level":"LOUD"}`))
+	assert.Equal(t, 400, put(`{"logger":"set environment","level":"DEBUG","ttl":"soon"}`))
+	assert.Equal(t, 200, put(`{"logger":"set environment","level":"debug","ttl":"15m"}`))
+	assert.Equal(t, zapcore.DebugLevel, levels.Level("set environment"))
+	audit.AssertNumberOfCalls(t, "RecordAudit", 1)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/log-levels", nil))
+	assert.NoError(t, err)
+	var body struct {
+		Data []logging.LevelState `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if assert.Len(t, body.Data, 2) {
+		assert.Equal(t, "INFO", body.Data[0].Level)
+		assert.Equal(t, "set environment", body.Data[1].Name)
+		assert.Equal(t, "DEBUG", body.Data[1].Level)
+		assert.NotNil(t, body.Data[1].ExpiresAt)
+	}
+}
@@ -0,0 +1,81 @@
+package user_test
+
+import (
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/metrics"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMetricsMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	app := fiber.New()
+	app.Use(middlewares.MetricsMiddleware())
+	app.Get("/metrics", metrics.Handler())
+	app.Get("/widgets/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "missing" {
+			return c.SendStatus(404)
+		}
+		return c.SendStatus(200)
+	})
+
+	for _, id := range []string{"1", "2", "missing"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/widgets/"+id, nil))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/widgets/:id", "GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/widgets/:id", "GET", "404")))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `http_request_duration_seconds_count{method="GET",route="/widgets/:id",status="200"} 2`)
+	assert.Contains(t, string(body), "go_goroutines")
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"))
+}
+
+func TestMongoMonitor_RecordsCommands(t *testing.T) {
+	monitor := metrics.MongoMonitor()
+	ctx := context.Background()
+
+	start := func(id int64) {
+		monitor.Started(ctx, &event.CommandStartedEvent{
+			Command:     bson.Raw(mustMarshal(t, bson.D{{Key: "find", Value: "metrics_test"}})),
+			CommandName: "find",
+			RequestID:   id,
+		})
+	}
+	finished := func(id int64) event.CommandFinishedEvent {
+		return event.CommandFinishedEvent{CommandName: "find", RequestID: id, Duration: 3 * time.Millisecond}
+	}
+
+	start(1)
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(1)})
+	start(2)
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished(2), Failure: "boom"})
+
+	var histogram dto.Metric
+	assert.NoError(t, metrics.MongoDuration.WithLabelValues("find", "metrics_test").(prometheus.Histogram).Write(&histogram))
+	assert.Equal(t, uint64(2), histogram.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.MongoErrors.WithLabelValues("find", "metrics_test")))
+}
+
+func mustMarshal(t *testing.T, doc interface{}) []byte {
+	raw, err := bson.Marshal(doc)
+	assert.NoError(t, err)
+	return raw
+}
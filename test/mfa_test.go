@@ -0,0 +1,208 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"context"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockMFARepo struct {
+	mock.Mock
+	// challenges mirrors the mfa_challenge collection, the usecase relies on
+	// its filters for single use and the attempt limit
+	mu         sync.Mutex
+	challenges map[string]*entities.MFAChallenge
+}
+
+func (m *mockMFARepo) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	args := m.Called(event, ctx)
+	return args.Error(0)
+}
+func (m *mockMFARepo) SetPendingMFASecret(userId string, secret string, ctx context.Context) error {
+	args := m.Called(userId, secret, ctx)
+	return args.Error(0)
+}
+func (m *mockMFARepo) EnableMFA(userId string, secret string, recoveryCodes []string, step int64, ctx context.Context) error {
+	args := m.Called(userId, secret, recoveryCodes, step, ctx)
+	return args.Error(0)
+}
+func (m *mockMFARepo) UseTOTPStep(userId string, step int64, ctx context.Context) error {
+	args := m.Called(userId, step, ctx)
+	return args.Error(0)
+}
+func (m *mockMFARepo) ConsumeRecoveryCode(userId string, codeHash string, ctx context.Context) error {
+	args := m.Called(userId, codeHash, ctx)
+	return args.Error(0)
+}
+
+func (m *mockMFARepo) CreateMFAChallenge(challenge entities.MFAChallenge, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.challenges == nil {
+		m.challenges = map[string]*entities.MFAChallenge{}
+	}
+	m.challenges[challenge.ID] = &challenge
+	return nil
+}
+func (m *mockMFARepo) UseMFAChallengeAttempt(challengeId string, userId string, maxAttempts int, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[challengeId]
+	if !ok || challenge.UserID != userId || challenge.UsedAt != nil || !challenge.ExpiresAt.After(time.Now()) || challenge.Attempts >= maxAttempts {
+		return errors.New("MFA challenge is invalid, expired, already used or has too many attempts")
+	}
+	challenge.Attempts++
+	return nil
+}
+func (m *mockMFARepo) ConsumeMFAChallenge(challengeId string, ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[challengeId]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(time.Now()) {
+		return errors.New("MFA challenge is invalid, expired or already used")
+	}
+	now := time.Now()
+	challenge.UsedAt = &now
+	return nil
+}
+
+func TestTOTPCode_RFC6238Vector(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	// RFC 6238 appendix B, SHA1, truncated to 6 digits
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Unix(59, 0)))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = utils.TOTPCode(secret, utils.TOTPStep(time.Unix(1111111109, 0)))
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+}
+
+func TestLogin_MFAChallenge(t *testing.T) {
+	repo := new(mockUserRepo)
+	mfaRepo := new(mockMFARepo)
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithMFA(mfaRepo, usecases.MFAConfig{Issuer: "test", ChallengeTTL: time.Minute}))
+	app := fiber.New()
+	app.Post("/auth/login", h.Login)
+	app.Post("/auth/mfa/verify", h.VerifyMFA)
+
+	secret, _ := utils.GenerateTOTPSecret()
+	user := entities.User{Email: "a@b.com", MFA: &entities.MFASettings{Enabled: true, Secret: secret}}
+	repo.On("Login", mock.AnythingOfType("entities.Login"), mock.Anything).Return("65f000000000000000000001", nil)
+	repo.On("GetUser", "65f000000000000000000001", mock.Anything).Return(user, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"a@b.com","password":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, true, body.Data["mfaRequired"])
+	assert.Nil(t, body.Data["accessToken"])
+	mfaToken, _ := body.Data["mfaToken"].(string)
+
+	// the challenge token is not an access token
+	_, err = utils.ParseToken(mfaToken)
+	assert.Error(t, err)
+
+	code, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+	mfaRepo.On("UseTOTPStep", "65f000000000000000000001", mock.Anything, mock.Anything).Return(nil)
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/mfa/verify", strings.NewReader(`{"mfaToken":"`+mfaToken+`","code":"`+code+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	mfaRepo.AssertExpectations(t)
+}
+
+func mfaChallengeToken(t *testing.T, app *fiber.App) string {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"a@b.com","password":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	mfaToken, _ := body.Data["mfaToken"].(string)
+	return mfaToken
+}
+
+func verifyMFA(t *testing.T, app *fiber.App, mfaToken, code string) int {
+	req := httptest.NewRequest(http.MethodPost, "/auth/mfa/verify", strings.NewReader(`{"mfaToken":"`+mfaToken+`","code":"`+code+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode
+}
+
+func TestVerifyMFA_ChallengeIsSingleUse(t *testing.T) {
+	repo := new(mockUserRepo)
+	mfaRepo := new(mockMFARepo)
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithMFA(mfaRepo, usecases.MFAConfig{Issuer: "test", ChallengeTTL: time.Minute}))
+	app := fiber.New()
+	app.Post("/auth/login", h.Login)
+	app.Post("/auth/mfa/verify", h.VerifyMFA)
+
+	secret, _ := utils.GenerateTOTPSecret()
+	user := entities.User{Email: "a@b.com", MFA: &entities.MFASettings{Enabled: true, Secret: secret}}
+	repo.On("Login", mock.AnythingOfType("entities.Login"), mock.Anything).Return("65f000000000000000000001", nil)
+	repo.On("GetUser", "65f000000000000000000001", mock.Anything).Return(user, nil)
+	mfaRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+	mfaRepo.On("ConsumeRecoveryCode", "65f000000000000000000001", mock.Anything, mock.Anything).Return(nil)
+
+	mfaToken := mfaChallengeToken(t, app)
+	assert.Equal(t, 200, verifyMFA(t, app, mfaToken, "aaaaa-bbbbb"))
+	// a second valid code cannot reuse the same challenge
+	assert.Equal(t, 401, verifyMFA(t, app, mfaToken, "ccccc-ddddd"))
+
+	// a new login gets a new challenge
+	assert.Equal(t, 200, verifyMFA(t, app, mfaChallengeToken(t, app), "ccccc-ddddd"))
+}
+
+func TestVerifyMFA_ChallengeAttemptLimit(t *testing.T) {
+	repo := new(mockUserRepo)
+	mfaRepo := new(mockMFARepo)
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithMFA(mfaRepo, usecases.MFAConfig{Issuer: "test", ChallengeTTL: time.Minute}))
+	app := fiber.New()
+	app.Post("/auth/login", h.Login)
+	app.Post("/auth/mfa/verify", h.VerifyMFA)
+
+	secret, _ := utils.GenerateTOTPSecret()
+	user := entities.User{Email: "a@b.com", MFA: &entities.MFASettings{Enabled: true, Secret: secret}}
+	repo.On("Login", mock.AnythingOfType("entities.Login"), mock.Anything).Return("65f000000000000000000001", nil)
+	repo.On("GetUser", "65f000000000000000000001", mock.Anything).Return(user, nil)
+	mfaRepo.On("ConsumeRecoveryCode", "65f000000000000000000001", mock.Anything, mock.Anything).Return(errors.New("invalid recovery code"))
+	mfaRepo.On("UseTOTPStep", "65f000000000000000000001", mock.Anything, mock.Anything).Return(nil)
+
+	mfaToken := mfaChallengeToken(t, app)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 401, verifyMFA(t, app, mfaToken, "000000-wrong"))
+	}
+
+	// the challenge is spent, even the right code is refused now
+	code, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+	assert.Equal(t, 401, verifyMFA(t, app, mfaToken, code))
+	mfaRepo.AssertNotCalled(t, "UseTOTPStep", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,445 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mockOAuthRepo struct {
+	mock.Mock
+}
+
+func (m *mockOAuthRepo) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	args := m.Called(event, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) CreateOAuthClient(client entities.OAuthClient, ctx context.Context) error {
+	args := m.Called(client, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) ListOAuthClients(ctx context.Context) ([]entities.OAuthClient, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]entities.OAuthClient), args.Error(1)
+}
+func (m *mockOAuthRepo) GetOAuthClient(clientId string, ctx context.Context) (entities.OAuthClient, error) {
+	args := m.Called(clientId, ctx)
+	return args.Get(0).(entities.OAuthClient), args.Error(1)
+}
+func (m *mockOAuthRepo) RevokeOAuthClient(clientId string, ctx context.Context) error {
+	args := m.Called(clientId, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) SaveOAuthConsent(consent entities.OAuthConsent, ctx context.Context) error {
+	args := m.Called(consent, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) GetOAuthConsent(userId string, clientId string, ctx context.Context) (entities.OAuthConsent, error) {
+	args := m.Called(userId, clientId, ctx)
+	return args.Get(0).(entities.OAuthConsent), args.Error(1)
+}
+func (m *mockOAuthRepo) ListOAuthConsents(userId string, ctx context.Context) ([]entities.OAuthConsent, error) {
+	args := m.Called(userId, ctx)
+	return args.Get(0).([]entities.OAuthConsent), args.Error(1)
+}
+func (m *mockOAuthRepo) RevokeOAuthConsent(userId string, clientId string, ctx context.Context) error {
+	args := m.Called(userId, clientId, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) CreateOAuthCode(code entities.OAuthCode, ctx context.Context) error {
+	args := m.Called(code, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) ConsumeOAuthCode(codeHash string, ctx context.Context) (entities.OAuthCode, error) {
+	args := m.Called(codeHash, ctx)
+	return args.Get(0).(entities.OAuthCode), args.Error(1)
+}
+func (m *mockOAuthRepo) CreateOAuthRefreshToken(token entities.OAuthRefreshToken, ctx context.Context) error {
+	args := m.Called(token, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) GetOAuthRefreshToken(tokenHash string, ctx context.Context) (entities.OAuthRefreshToken, error) {
+	args := m.Called(tokenHash, ctx)
+	return args.Get(0).(entities.OAuthRefreshToken), args.Error(1)
+}
+func (m *mockOAuthRepo) ConsumeOAuthRefreshToken(tokenHash string, ctx context.Context) (entities.OAuthRefreshToken, error) {
+	args := m.Called(tokenHash, ctx)
+	return args.Get(0).(entities.OAuthRefreshToken), args.Error(1)
+}
+func (m *mockOAuthRepo) RevokeOAuthRefreshFamily(family string, ctx context.Context) error {
+	args := m.Called(family, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) RevokeAccessToken(tokenId string, expiresAt time.Time, ctx context.Context) error {
+	args := m.Called(tokenId, expiresAt, ctx)
+	return args.Error(0)
+}
+func (m *mockOAuthRepo) IsAccessTokenRevoked(tokenId string, ctx context.Context) (bool, error) {
+	args := m.Called(tokenId, ctx)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockOAuthRepo) OAuthTokensRevokedAt(clientId string, userId string, ctx context.Context) (time.Time, error) {
+	args := m.Called(clientId, userId, ctx)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+func (m *mockOAuthRepo) TokensRevokedAt(userId string, ctx context.Context) (time.Time, error) {
+	args := m.Called(userId, ctx)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func setupOAuthApp(repo *mockOAuthRepo, userId string) *fiber.App {
+	h := usecases.NewHttpOAuth(validator.New(), repo, usecases.OAuthConfig{
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 24 * time.Hour,
+		CodeTTL:         time.Minute,
+	})
+	app := fiber.New()
+	app.Post("/oauth/authorize", withUser(userId), h.Authorize)
+	app.Post("/oauth/token", h.Token)
+	app.Post("/oauth/introspect", h.Introspect)
+	app.Post("/oauth/revoke", h.Revoke)
+	app.Get("/protected", middlewares.JWTMiddleware(middlewares.JWTConfig{AccessTokens: repo}), func(c *fiber.Ctx) error {
+		principal, _ := entities.PrincipalFromContext(c.UserContext())
+		return c.JSON(principal)
+	})
+	return app
+}
+
+func postForm(t *testing.T, app *fiber.App, path string, form url.Values, clientId, secret string) (*http.Response, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientId != "" {
+		req.SetBasicAuth(clientId, secret)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body
+}
+
+var webClient = entities.OAuthClient{
+	ClientID:     "web-app",
+	SecretHash:   utils.Hash("web-secret"),
+	Name:         "Web App",
+	RedirectURIs: []string{"https://app.local/callback"},
+	GrantTypes:   []string{entities.GrantAuthorizationCode, entities.GrantRefreshToken},
+	Scopes:       []string{entities.ScopeUsersRead, entities.ScopeUsersWrite},
+}
+
+func TestOAuthAuthorizationCodeWithPKCE(t *testing.T) {
+	repo := new(mockOAuthRepo)
+	app := setupOAuthApp(repo, "user-1")
+
+	repo.On("GetOAuthClient", "web-app", mock.Anything).Return(webClient, nil)
+	repo.On("GetOAuthConsent", "user-1", "web-app", mock.Anything).Return(entities.OAuthConsent{}, mongo.ErrNoDocuments)
+	repo.On("SaveOAuthConsent", mock.MatchedBy(func(c entities.OAuthConsent) bool { return c.UserID == "user-1" }), mock.Anything).Return(nil)
+	repo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+	var code entities.OAuthCode
+	repo.On("CreateOAuthCode", mock.AnythingOfType("entities.OAuthCode"), mock.Anything).
+		Run(func(args mock.Arguments) { code = args.Get(0).(entities.OAuthCode) }).Return(nil)
+
+	verifier, _ := utils.GeneratePKCEVerifier()
+	authorize := map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             "web-app",
+		"redirect_uri":          "https://app.local/callback",
+		"scope":                 "users:read",
+		"state":                 "xyz",
+		"code_challenge":        utils.PKCEChallenge(verifier),
+		"code_challenge_method": "S256",
+	}
+
+	// without approval the user is asked for consent first
+	body, _ := json.Marshal(authorize)
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	authorize["approve"] = true
+	body, _ = json.Marshal(authorize)
+	req = httptest.NewRequest(http.MethodPost, "/oauth/authorize", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var authorized struct {
+		Data map[string]string `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&authorized)
+	redirect, _ := url.Parse(authorized.Data["redirectUri"])
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	rawCode := redirect.Query().Get("code")
+	assert.Equal(t, utils.Hash(rawCode), code.ID)
+
+	repo.On("ConsumeOAuthCode", code.ID, mock.Anything).Return(code, nil).Once()
+	resp, tokens := postForm(t, app, "/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {rawCode},
+		"redirect_uri":  {"https://app.local/callback"},
+		"code_verifier": {"wrong-verifier"},
+	}, "web-app", "web-secret")
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "invalid_grant", tokens["error"])
+
+	repo.On("ConsumeOAuthCode", code.ID, mock.Anything).Return(code, nil).Once()
+	repo.On("CreateOAuthRefreshToken", mock.AnythingOfType("entities.OAuthRefreshToken"), mock.Anything).Return(nil)
+	resp, tokens = postForm(t, app, "/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {rawCode},
+		"redirect_uri":  {"https://app.local/callback"},
+		"code_verifier": {verifier},
+	}, "web-app", "web-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "users:read", tokens["scope"])
+	assert.NotEmpty(t, tokens["refresh_token"])
+
+	claims, err := utils.ParseToken(tokens["access_token"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "web-app", claims.ClientID)
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	repo := new(mockOAuthRepo)
+	app := setupOAuthApp(repo, "")
+
+	batch := entities.OAuthClient{
+		ClientID:   "batch",
+		SecretHash: utils.Hash("batch-secret"),
+		GrantTypes: []string{entities.GrantClientCredentials},
+		Scopes:     []string{entities.ScopeUsersRead},
+	}
+	repo.On("GetOAuthClient", "batch", mock.Anything).Return(batch, nil)
+
+	resp, body := postForm(t, app, "/oauth/token", url.Values{"grant_type": {"client_credentials"}}, "batch", "wrong")
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	resp, body = postForm(t, app, "/oauth/token", url.Values{"grant_type": {"client_credentials"}, "scope": {"users:write"}}, "batch", "batch-secret")
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "invalid_scope", body["error"])
+
+	resp, body = postForm(t, app, "/oauth/token", url.Values{"grant_type": {"client_credentials"}}, "batch", "batch-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Nil(t, body["refresh_token"])
+
+	// the token authenticates the client itself as a service principal
+	repo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("OAuthTokensRevokedAt", "batch", "", mock.Anything).Return(time.Time{}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+	resp, _ = app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var principal entities.Principal
+	json.NewDecoder(resp.Body).Decode(&principal)
+	assert.Equal(t, entities.PrincipalService, principal.Type)
+	assert.Equal(t, "batch", principal.ID)
+	assert.Equal(t, []string{entities.ScopeUsersRead}, principal.Scopes)
+}
+
+func TestOAuthClients_NeverGetAdmin(t *testing.T) {
+	repo := new(mockOAuthRepo)
+	h := usecases.NewHttpOAuth(validator.New(), repo, usecases.OAuthConfig{AccessTokenTTL: time.Hour, RefreshTokenTTL: time.Hour, CodeTTL: time.Minute})
+	admin := fiber.New()
+	admin.Post("/oauth-clients", h.CreateClient)
+	req := httptest.NewRequest(http.MethodPost, "/oauth-clients", strings.NewReader(`{"name":"ops","grantTypes":["client_credentials"],"scopes":["users:read","admin"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := admin.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+	repo.AssertNotCalled(t, "CreateOAuthClient", mock.Anything, mock.Anything)
+
+	// a client stored with admin before it was refused
+	app := setupOAuthApp(repo, "")
+	repo.On("GetOAuthClient", "ops", mock.Anything).Return(entities.OAuthClient{
+		ClientID:   "ops",
+		SecretHash: utils.Hash("ops-secret"),
+		GrantTypes: []string{entities.GrantClientCredentials},
+		Scopes:     []string{entities.ScopeUsersRead, entities.ScopeAdmin},
+	}, nil)
+	resp, body := postForm(t, app, "/oauth/token", url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}}, "ops", "ops-secret")
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "invalid_scope", body["error"])
+	resp, body = postForm(t, app, "/oauth/token", url.Values{"grant_type": {"client_credentials"}}, "ops", "ops-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, entities.ScopeUsersRead, body["scope"])
+}
+
+func TestOAuthRefreshTokenReuseRevokesFamily(t *testing.T) {
+	repo := new(mockOAuthRepo)
+	app := setupOAuthApp(repo, "")
+
+	usedAt := time.Now().Add(-time.Minute)
+	repo.On("GetOAuthClient", "web-app", mock.Anything).Return(webClient, nil)
+	repo.On("ConsumeOAuthRefreshToken", utils.Hash("stolen"), mock.Anything).Return(entities.OAuthRefreshToken{
+		ID:        utils.Hash("stolen"),
+		Family:    "family-1",
+		ClientID:  "web-app",
+		UserID:    "user-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &usedAt,
+	}, nil)
+	repo.On("RevokeOAuthRefreshFamily", "family-1", mock.Anything).Return(nil)
+	repo.On("RecordAudit", mock.MatchedBy(func(e entities.AuditEvent) bool { return e.Action == entities.AuditOAuthRefreshReuse }), mock.Anything).Return(nil)
+
+	resp, body := postForm(t, app, "/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"stolen"}}, "web-app", "web-secret")
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "invalid_grant", body["error"])
+	repo.AssertExpectations(t)
+}
+
+func TestOAuthRevokeAndIntrospectAccessToken(t *testing.T) {
+	repo := new(mockOAuthRepo)
+	app := setupOAuthApp(repo, "")
+
+	repo.On("GetOAuthClient", "web-app", mock.Anything).Return(webClient, nil)
+	claims := utils.Claims{UserID: "user-1", ClientID: "web-app", Scope: "users:read"}
+	claims.ID = "token-1"
+	token, _ := utils.GenerateTokenWithClaims(claims, time.Hour)
+
+	repo.On("IsAccessTokenRevoked", "token-1", mock.Anything).Return(false, nil).Once()
+	repo.On("OAuthTokensRevokedAt", "web-app", "user-1", mock.Anything).Return(time.Time{}, nil)
+	resp, body := postForm(t, app, "/oauth/introspect", url.Values{"token": {token}}, "web-app", "web-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, true, body["active"])
+	assert.Equal(t, "user-1", body["sub"])
+
+	repo.On("GetOAuthRefreshToken", utils.Hash(token), mock.Anything).Return(entities.OAuthRefreshToken{}, mongo.ErrNoDocuments)
+	repo.On("RevokeAccessToken", "token-1", mock.Anything, mock.Anything).Return(nil)
+	resp, _ = postForm(t, app, "/oauth/revoke", url.Values{"token": {token}}, "web-app", "web-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	repo.AssertCalled(t, "RevokeAccessToken", "token-1", mock.Anything, mock.Anything)
+
+	repo.On("IsAccessTokenRevoked", "token-1", mock.Anything).Return(true, nil)
+	resp, body = postForm(t, app, "/oauth/introspect", url.Values{"token": {token}}, "web-app", "web-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, false, body["active"])
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, _ = app.Test(req)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestOAuthAccessTokens_EndWithClientOrConsent(t *testing.T) {
+	issued := func(clientId, userId string) string {
+		claims := utils.Claims{UserID: userId, ClientID: clientId, Scope: "users:read"}
+		claims.ID = clientId + userId
+		token, _ := utils.GenerateTokenWithClaims(claims, time.Hour)
+		return token
+	}
+	protected := func(app *fiber.App, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	repo := new(mockOAuthRepo)
+	app := setupOAuthApp(repo, "")
+	repo.On("GetOAuthClient", "web-app", mock.Anything).Return(webClient, nil)
+	repo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("OAuthTokensRevokedAt", "web-app", "user-1", mock.Anything).Return(time.Now().Add(-time.Minute), nil)
+	token := issued("web-app", "user-1")
+	assert.Equal(t, 200, protected(app, token))
+
+	// the client or the consent was revoked after the token was issued
+	repo = new(mockOAuthRepo)
+	app = setupOAuthApp(repo, "")
+	repo.On("GetOAuthClient", "web-app", mock.Anything).Return(webClient, nil)
+	repo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	repo.On("OAuthTokensRevokedAt", "web-app", "user-1", mock.Anything).Return(time.Now(), nil)
+	repo.On("OAuthTokensRevokedAt", "batch", "", mock.Anything).Return(time.Now(), nil)
+	assert.Equal(t, 401, protected(app, token))
+	assert.Equal(t, 401, protected(app, issued("batch", "")))
+
+	resp, body := postForm(t, app, "/oauth/introspect", url.Values{"token": {token}}, "web-app", "web-secret")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, false, body["active"])
+}
+
+func TestOAuthUserTokens_CannotUseSelfService(t *testing.T) {
+	repo := new(mockUserRepo)
+	oauthRepo := new(mockOAuthRepo)
+	h := usecases.NewHttpUser(validator.New(), repo)
+	oauth := usecases.NewHttpOAuth(validator.New(), oauthRepo, usecases.OAuthConfig{AccessTokenTTL: time.Hour, RefreshTokenTTL: time.Hour, CodeTTL: time.Minute})
+
+	// mirrors routers.SetupRoutes
+	app := fiber.New()
+	jwt := middlewares.JWTMiddleware(middlewares.JWTConfig{AccessTokens: oauthRepo})
+	firstParty := middlewares.RequireFirstParty()
+	app.Post("/oauth/authorize", jwt, firstParty, oauth.Authorize)
+	users := app.Group("/users", jwt)
+	users.Get("/me", middlewares.RequireScope(entities.ScopeUsersRead), h.GetMe)
+	users.Patch("/me", firstParty, h.UpdateMe)
+	users.Delete("/me", firstParty, h.DeleteMe)
+	users.Post("/me/cancel-deletion", firstParty, h.CancelDeleteMe)
+	users.Post("/me/password", firstParty, h.ChangePassword)
+	users.Post("/me/mfa/enroll", firstParty, h.EnrollMFA)
+	users.Post("/me/mfa/confirm", firstParty, h.ConfirmMFA)
+	users.Get("/me/sessions", firstParty, h.ListMySessions)
+	users.Delete("/me/sessions/:id", firstParty, h.RevokeMySession)
+	users.Get("/me/consents", firstParty, oauth.ListMyConsents)
+	users.Delete("/me/consents/:clientId", firstParty, oauth.RevokeMyConsent)
+
+	oauthRepo.On("IsAccessTokenRevoked", mock.Anything, mock.Anything).Return(false, nil)
+	oauthRepo.On("OAuthTokensRevokedAt", mock.Anything, mock.Anything, mock.Anything).Return(time.Time{}, nil)
+	call := func(method, path, scope, clientId string) int {
+		claims := utils.Claims{UserID: "65f000000000000000000001", ClientID: clientId, Scope: scope}
+		claims.ID = "token-1"
+		token, _ := utils.GenerateTokenWithClaims(claims, time.Hour)
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/oauth/authorize"},
+		{http.MethodPatch, "/users/me"},
+		{http.MethodDelete, "/users/me"},
+		{http.MethodPost, "/users/me/cancel-deletion"},
+		{http.MethodPost, "/users/me/password"},
+		{http.MethodPost, "/users/me/mfa/enroll"},
+		{http.MethodPost, "/users/me/mfa/confirm"},
+		{http.MethodGet, "/users/me/sessions"},
+		{http.MethodDelete, "/users/me/sessions/session-1"},
+		{http.MethodGet, "/users/me/consents"},
+		{http.MethodDelete, "/users/me/consents/other-app"},
+	}
+	for _, route := range routes {
+		assert.Equal(t, 403, call(route.method, route.path, "users:read users:write", "web-app"), route.method+" "+route.path)
+	}
+
+	// the user's own login still reaches the handler
+	assert.Equal(t, 400, call(http.MethodPost, "/oauth/authorize", "", ""))
+
+	// reading the profile is what a users:read grant is for
+	repo.On("GetUser", "65f000000000000000000001", mock.Anything).Return(entities.User{Email: "a@b.com"}, nil)
+	assert.Equal(t, 200, call(http.MethodGet, "/users/me", "users:read", "web-app"))
+	assert.Equal(t, 403, call(http.MethodGet, "/users/me", "users:write", "web-app"))
+	assert.Equal(t, 200, call(http.MethodGet, "/users/me", "", ""))
+}
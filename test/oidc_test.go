@@ -0,0 +1,425 @@
+package user_test
+
+import (
+	oidc "backend-challenge/adapters/oidc"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mockIdP is a minimal in-process OpenID provider: discovery, authorize,
+// token (with PKCE check) and JWKS.
+type mockIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+	subject  string
+	email    string
+	verified interface{}
+	audience string // overrides the aud claim when set
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	idp := &mockIdP{key: key, clientID: "backend", secret: "s3cret", subject: "idp-user-1", email: "tee@email.com", verified: true, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code, _ := utils.RandomToken(16)
+		idp.mu.Lock()
+		idp.codes[code] = q
+		idp.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?state="+url.QueryEscape(q.Get("state"))+"&code="+code, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		user, pass, _ := r.BasicAuth()
+		idp.mu.Lock()
+		auth, ok := idp.codes[r.Form.Get("code")]
+		delete(idp.codes, r.Form.Get("code"))
+		idp.mu.Unlock()
+		if user != idp.clientID || pass != idp.secret || !ok || !utils.VerifyPKCE(r.Form.Get("code_verifier"), auth.Get("code_challenge")) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "idp-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken(t, auth.Get("nonce")),
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) idToken(t *testing.T, nonce string) string {
+	aud := idp.clientID
+	if idp.audience != "" {
+		aud = idp.audience
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            idp.subject,
+		"aud":            aud,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          idp.email,
+		"email_verified": idp.verified,
+		"name":           "Tee",
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(idp.key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func (idp *mockIdP) provider() *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       idp.server.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: idp.secret,
+		RedirectURL:  "http://localhost/auth/oidc/callback",
+	})
+}
+
+// authorize plays the browser: it follows the authorization URL and returns
+// the code from the redirect back to us.
+func (idp *mockIdP) authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return location.Query()
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	verifier, _ := utils.GeneratePKCEVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", utils.PKCEChallenge(verifier))
+	assert.NoError(t, err)
+
+	callback := idp.authorize(t, authURL)
+	assert.Equal(t, "state-1", callback.Get("state"))
+
+	token, err := provider.Exchange(ctx, callback.Get("code"), verifier)
+	assert.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "idp-user-1", claims.Subject)
+	assert.Equal(t, "tee@email.com", claims.Email)
+	assert.True(t, bool(claims.EmailVerified))
+
+	_, err = provider.VerifyIDToken(ctx, token.IDToken, "other-nonce")
+	assert.Error(t, err)
+}
+
+func TestOIDCProvider_RejectsWrongVerifierAndAudience(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	verifier, _ := utils.GeneratePKCEVerifier()
+	authURL, _ := provider.AuthCodeURL(ctx, "state-1", "nonce-1", utils.PKCEChallenge(verifier))
+	callback := idp.authorize(t, authURL)
+	_, err := provider.Exchange(ctx, callback.Get("code"), "wrong-verifier")
+	assert.Error(t, err)
+
+	idp.audience = "someone-else"
+	authURL, _ = provider.AuthCodeURL(ctx, "state-2", "nonce-2", utils.PKCEChallenge(verifier))
+	callback = idp.authorize(t, authURL)
+	token, err := provider.Exchange(ctx, callback.Get("code"), verifier)
+	assert.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, token.IDToken, "nonce-2")
+	assert.Error(t, err)
+}
+
+type mockOIDCRepo struct {
+	mock.Mock
+}
+
+func (m *mockOIDCRepo) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	args := m.Called(event, ctx)
+	return args.Error(0)
+}
+func (m *mockOIDCRepo) CreateOIDCLogin(login entities.OIDCLogin, ctx context.Context) error {
+	args := m.Called(login, ctx)
+	return args.Error(0)
+}
+func (m *mockOIDCRepo) ConsumeOIDCLogin(state string, ctx context.Context) (entities.OIDCLogin, error) {
+	args := m.Called(state, ctx)
+	return args.Get(0).(entities.OIDCLogin), args.Error(1)
+}
+func (m *mockOIDCRepo) FindUserByIdentity(provider string, subject string, ctx context.Context) (entities.User, error) {
+	args := m.Called(provider, subject, ctx)
+	return args.Get(0).(entities.User), args.Error(1)
+}
+func (m *mockOIDCRepo) GetUserByEmail(email string, ctx context.Context) (entities.User, error) {
+	args := m.Called(email, ctx)
+	return args.Get(0).(entities.User), args.Error(1)
+}
+func (m *mockOIDCRepo) LinkIdentity(userId string, identity entities.ExternalIdentity, ctx context.Context) error {
+	args := m.Called(userId, identity, ctx)
+	return args.Error(0)
+}
+
+// startOIDCLogin runs /auth/oidc/login and the provider redirect, and returns
+// the callback query and the state cookie set for the browser.
+func startOIDCLogin(t *testing.T, idp *mockIdP, repo *mockUserRepo, oidcRepo *mockOIDCRepo, options ...usecases.Option) (*fiber.App, url.Values, *http.Cookie) {
+	options = append(options, usecases.WithOIDC(idp.provider(), oidcRepo, usecases.OIDCConfig{ProviderName: "test", StateTTL: time.Minute}))
+	h := usecases.NewHttpUser(validator.New(), repo, options...)
+	app := fiber.New()
+	app.Get("/auth/oidc/login", h.OIDCLogin)
+	app.Get("/auth/oidc/callback", h.OIDCCallback)
+	app.Post("/auth/mfa/verify", h.VerifyMFA)
+
+	var stored entities.OIDCLogin
+	oidcRepo.On("CreateOIDCLogin", mock.AnythingOfType("entities.OIDCLogin"), mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(entities.OIDCLogin) }).Return(nil)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 302, resp.StatusCode)
+
+	var stateCookie *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "oidc_state" {
+			stateCookie = cookie
+		}
+	}
+	if assert.NotNil(t, stateCookie) {
+		assert.True(t, stateCookie.HttpOnly)
+		assert.Equal(t, stored.State, stateCookie.Value)
+	}
+
+	callback := idp.authorize(t, resp.Header.Get("Location"))
+	oidcRepo.On("ConsumeOIDCLogin", stored.State, mock.Anything).Return(stored, nil)
+	return app, callback, stateCookie
+}
+
+func runOIDCLogin(t *testing.T, idp *mockIdP, repo *mockUserRepo, oidcRepo *mockOIDCRepo, options ...usecases.Option) *http.Response {
+	app, callback, stateCookie := startOIDCLogin(t, idp, repo, oidcRepo, options...)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+callback.Encode(), nil)
+	if stateCookie != nil {
+		req.AddCookie(stateCookie)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestOIDCCallback_LinksVerifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	existing := entities.User{ID: primitive.NewObjectID(), Email: "tee@email.com", EmailVerified: true}
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(existing, nil)
+	oidcRepo.On("LinkIdentity", existing.ID.Hex(), mock.MatchedBy(func(i entities.ExternalIdentity) bool { return i.Subject == "idp-user-1" }), mock.Anything).Return(nil)
+	oidcRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data map[string]string `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	claims, err := utils.ParseToken(body.Data["accessToken"])
+	assert.NoError(t, err)
+	assert.Equal(t, existing.ID.Hex(), claims.UserID)
+	oidcRepo.AssertExpectations(t)
+}
+
+func TestOIDCCallback_UnverifiedEmailIsNotLinked(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.verified = false
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(entities.User{ID: primitive.NewObjectID()}, nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 403, resp.StatusCode)
+	oidcRepo.AssertNotCalled(t, "LinkIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCCallback_UnverifiedLocalAccountIsNotLinked(t *testing.T) {
+	idp := newMockIdP(t)
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	// anyone can register an address they do not own; SSO must not adopt it
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(entities.User{ID: primitive.NewObjectID(), Email: "tee@email.com", Password: "attacker-hash"}, nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 403, resp.StatusCode)
+	oidcRepo.AssertNotCalled(t, "LinkIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCCallback_EmailVerifiedAsString(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.verified = "true"
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	existing := entities.User{ID: primitive.NewObjectID(), Email: "tee@email.com", EmailVerified: true}
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(existing, nil)
+	oidcRepo.On("LinkIdentity", existing.ID.Hex(), mock.Anything, mock.Anything).Return(nil)
+	oidcRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 200, resp.StatusCode)
+	oidcRepo.AssertExpectations(t)
+}
+
+func TestOIDCCallback_ProvisionsNewUser(t *testing.T) {
+	idp := newMockIdP(t)
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(entities.User{}, errors.New("not found"))
+	oidcRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CheckDuplicateUser", "tee@email.com", mock.Anything).Return(nil)
+	repo.On("Register", mock.MatchedBy(func(u entities.User) bool {
+		return u.EmailVerified && len(u.Identities) == 1 && u.Password == ""
+	}), mock.Anything).Return(nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 200, resp.StatusCode)
+	repo.AssertExpectations(t)
+}
+
+func TestOIDCCallback_StateMustMatchBrowser(t *testing.T) {
+	idp := newMockIdP(t)
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	// the callback URL is replayed in a browser that did not start the login
+	app, callback, _ := startOIDCLogin(t, idp, repo, oidcRepo)
+	for _, cookie := range []*http.Cookie{nil, {Name: "oidc_state", Value: "other-state"}} {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+callback.Encode(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	}
+	oidcRepo.AssertNotCalled(t, "ConsumeOIDCLogin", mock.Anything, mock.Anything)
+}
+
+func TestOIDCCallback_HidesProviderErrors(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.audience = "someone-else"
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	var body entities.Response
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Could not sign in with the identity provider", body.ErrorMessage)
+}
+
+func TestOIDCCallback_MFAUserGetsChallenge(t *testing.T) {
+	idp := newMockIdP(t)
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+	mfaRepo := new(mockMFARepo)
+
+	secret, _ := utils.GenerateTOTPSecret()
+	existing := entities.User{ID: primitive.NewObjectID(), Email: "tee@email.com", EmailVerified: true, MFA: &entities.MFASettings{Enabled: true, Secret: secret}}
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(existing, nil)
+	repo.On("GetUser", existing.ID.Hex(), mock.Anything).Return(existing, nil)
+
+	resp := runOIDCLogin(t, idp, repo, oidcRepo, usecases.WithMFA(mfaRepo, usecases.MFAConfig{Issuer: "test", ChallengeTTL: time.Minute}))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, true, body.Data["mfaRequired"])
+	assert.Nil(t, body.Data["accessToken"])
+	assert.NotEmpty(t, body.Data["mfaToken"])
+}
+
+func TestOIDCCallback_RequiresVerifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.verified = false
+	repo := new(mockUserRepo)
+	oidcRepo := new(mockOIDCRepo)
+
+	// provisioned with the provider's unverified email
+	oidcRepo.On("FindUserByIdentity", "test", "idp-user-1", mock.Anything).Return(entities.User{}, mongo.ErrNoDocuments)
+	oidcRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(entities.User{}, errors.New("not found"))
+	oidcRepo.On("RecordAudit", mock.Anything, mock.Anything).Return(nil)
+	repo.On("CheckDuplicateUser", "tee@email.com", mock.Anything).Return(nil)
+	repo.On("Register", mock.AnythingOfType("entities.User"), mock.Anything).Return(nil)
+	repo.On("GetUser", mock.Anything, mock.Anything).Return(entities.User{Email: "tee@email.com"}, nil)
+
+	verification := usecases.WithEmailVerification(new(mockVerificationRepo), mailer.NewMemoryMailer("test@local"), usecases.VerificationConfig{TTL: time.Hour, RequireVerified: true})
+	resp := runOIDCLogin(t, idp, repo, oidcRepo, verification)
+	assert.Equal(t, 403, resp.StatusCode)
+}
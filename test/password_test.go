@@ -0,0 +1,140 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/usecases"
+	"backend-challenge/utils"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type mockPasswordRepo struct {
+	mock.Mock
+}
+
+func (m *mockPasswordRepo) RecordAudit(event entities.AuditEvent, ctx context.Context) error {
+	args := m.Called(event, ctx)
+	return args.Error(0)
+}
+func (m *mockPasswordRepo) GetUserByEmail(email string, ctx context.Context) (entities.User, error) {
+	args := m.Called(email, ctx)
+	return args.Get(0).(entities.User), args.Error(1)
+}
+func (m *mockPasswordRepo) CreatePasswordReset(reset entities.PasswordReset, ctx context.Context) error {
+	args := m.Called(reset, ctx)
+	return args.Error(0)
+}
+func (m *mockPasswordRepo) ConsumePasswordReset(tokenHash string, ctx context.Context) (entities.PasswordReset, error) {
+	args := m.Called(tokenHash, ctx)
+	return args.Get(0).(entities.PasswordReset), args.Error(1)
+}
+func (m *mockPasswordRepo) InvalidatePasswordResets(userId string, ctx context.Context) error {
+	args := m.Called(userId, ctx)
+	return args.Error(0)
+}
+func (m *mockPasswordRepo) UpdatePassword(userId string, passwordHash string, ctx context.Context) error {
+	args := m.Called(userId, passwordHash, ctx)
+	return args.Error(0)
+}
+
+func setupPasswordApp(repo *mockUserRepo, passwordRepo *mockPasswordRepo, mail mailer.Mailer, userId string) *fiber.App {
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithPasswordReset(passwordRepo, mail, usecases.PasswordResetConfig{
+		TTL:     time.Hour,
+		LinkURL: "http://localhost/reset",
+	}))
+	app := fiber.New()
+	app.Post("/auth/forgot-password", h.ForgotPassword)
+	app.Post("/auth/reset-password", h.ResetPassword)
+	app.Post("/users/me/password", withUser(userId), h.ChangePassword)
+	return app
+}
+
+func withUser(userId string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.SetUserContext(context.WithValue(c.UserContext(), entities.UserIDKey, userId))
+		return c.Next()
+	}
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	passwordRepo := new(mockPasswordRepo)
+	mail := mailer.NewMemoryMailer("test@local")
+	app := setupPasswordApp(repo, passwordRepo, mail, "")
+
+	user := entities.User{ID: primitive.NewObjectID(), Name: "Tee", Email: "tee@email.com"}
+	var stored entities.PasswordReset
+	passwordRepo.On("GetUserByEmail", "tee@email.com", mock.Anything).Return(user, nil)
+	passwordRepo.On("InvalidatePasswordResets", user.ID.Hex(), mock.Anything).Return(nil)
+	passwordRepo.On("CreatePasswordReset", mock.AnythingOfType("entities.PasswordReset"), mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(entities.PasswordReset) }).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(`{"email":"tee@email.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	messages := mail.Messages()
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	token := regexp.MustCompile(`token=([^"&\s]+)`).FindStringSubmatch(messages[0].Text)[1]
+	// only the hash is persisted
+	assert.Equal(t, utils.Hash(token), stored.ID)
+	assert.NotContains(t, stored.ID, token)
+
+	passwordRepo.On("ConsumePasswordReset", utils.Hash(token), mock.Anything).Return(stored, nil)
+	passwordRepo.On("UpdatePassword", user.ID.Hex(), utils.Hash("new-password"), mock.Anything).Return(nil)
+	passwordRepo.On("RecordAudit", mock.MatchedBy(func(e entities.AuditEvent) bool { return e.Action == entities.AuditPasswordReset }), mock.Anything).Return(nil)
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/reset-password", strings.NewReader(`{"token":"`+token+`","newPassword":"new-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	passwordRepo.AssertExpectations(t)
+}
+
+func TestChangePassword_WrongCurrentPassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	passwordRepo := new(mockPasswordRepo)
+	app := setupPasswordApp(repo, passwordRepo, mailer.NewMemoryMailer("test@local"), "abc123")
+
+	repo.On("GetUser", "abc123", mock.Anything).Return(entities.User{Password: utils.Hash("old-password")}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/me/password", strings.NewReader(`{"currentPassword":"wrong","newPassword":"new-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 400, resp.StatusCode)
+	passwordRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestChangePassword(t *testing.T) {
+	repo := new(mockUserRepo)
+	passwordRepo := new(mockPasswordRepo)
+	app := setupPasswordApp(repo, passwordRepo, mailer.NewMemoryMailer("test@local"), "abc123")
+
+	repo.On("GetUser", "abc123", mock.Anything).Return(entities.User{Password: utils.Hash("old-password")}, nil)
+	passwordRepo.On("UpdatePassword", "abc123", utils.Hash("new-password"), mock.Anything).Return(nil)
+	passwordRepo.On("RecordAudit", mock.MatchedBy(func(e entities.AuditEvent) bool { return e.Action == entities.AuditPasswordChanged }), mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/me/password", strings.NewReader(`{"currentPassword":"old-password","newPassword":"new-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	passwordRepo.AssertExpectations(t)
+}
@@ -0,0 +1,172 @@
+package user_test
+
+import (
+	"backend-challenge/configs"
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/health"
+	"backend-challenge/pkg/logging"
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const reloadConfig = `
+app:
+  port: 7000
+jwt:
+  secret: reload-secret
+mongo:
+  uri: mongodb://mongo:27017
+  db: appdb
+log:
+  level: INFO
+cors:
+  allow_origins: [https://a.example.com]
+`
+
+func newTestReloader(t *testing.T, path string) (*configs.Reloader, *health.Registry, map[string]int) {
+	withConfigRestored(t)
+	base := logging.Levels.Level("")
+	t.Cleanup(func() { logging.Levels.SetBase(base) })
+
+	loader := configs.Loader{Args: []string{"--config", path}, Env: envconfig.MapLookuper(nil)}
+	snapshot, err := loader.Load(context.Background())
+	assert.NoError(t, err)
+
+	registry := health.NewRegistry(time.Second)
+	reloader := configs.NewReloader(loader, snapshot, registry, zap.NewNop().Sugar())
+	calls := map[string]int{}
+	reloader.Live(func(fresh configs.Fresh) {
+		calls["log"]++
+		level, _ := logging.ParseLevel(configs.FreshSection(fresh, logging.L).LogLevel)
+		logging.Levels.SetBase(level)
+	}, "LOG_LEVEL")
+	reloader.Live(func(fresh configs.Fresh) {
+		calls["cors"]++
+		calls["cors_headers"] = len(configs.FreshSection(fresh, configs.CORS).AllowHeaders)
+	}, "CORS_ALLOW_ORIGINS", "CORS_ALLOW_HEADERS")
+	return reloader, registry, calls
+}
+
+func TestReloader_AppliesLiveKeys(t *testing.T) {
+	path := writeFile(t, "app.yaml", reloadConfig)
+	reloader, registry, calls := newTestReloader(t, path)
+	assert.Equal(t, 1, reloader.Status().Version)
+
+	result, err := reloader.Reload(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, configs.ReloadUnchanged, result.Status)
+	assert.Empty(t, calls)
+
+	changed := reloadConfig + "  allow_headers: [Authorization]\n"
+	changed = strings.Replace(changed, "  level: INFO", "  level: DEBUG", 1)
+	assert.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+
+	result, err = reloader.Reload(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, configs.ReloadApplied, result.Status)
+	assert.Equal(t, []string{"CORS_ALLOW_HEADERS", "LOG_LEVEL"}, result.Changed)
+	// each applier runs once, however many of its keys changed
+	assert.Equal(t, map[string]int{"log": 1, "cors": 1, "cors_headers": 1}, calls)
+	assert.Equal(t, zapcore.DebugLevel, logging.Levels.Level(""))
+	// the sections keep the startup values, the appliers hold the live ones
+	assert.Equal(t, "INFO", logging.L.LogLevel)
+	assert.NotEqual(t, []string{"Authorization"}, configs.CORS.AllowHeaders)
+
+	report := registry.Run(context.Background())
+	if assert.NotNil(t, report.Config) {
+		assert.Equal(t, 2, report.Config.Version)
+		assert.Equal(t, configs.ReloadApplied, report.Config.LastReload.Status)
+	}
+}
+
+// Run with -race: requests read the config sections without locks while a
+// reload is applied.
+func TestReloader_DoesNotWriteSections(t *testing.T) {
+	path := writeFile(t, "app.yaml", reloadConfig)
+	reloader, _, _ := newTestReloader(t, path)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = logging.L.LogLevel + strings.Join(configs.CORS.AllowOrigins, ",") + configs.App.UserCountInterval.String()
+			}
+		}
+	}()
+
+	for _, level := range []string{"DEBUG", "WARNING", "INFO"} {
+		changed := strings.Replace(reloadConfig, "  level: INFO", "  level: "+level, 1) + "  allow_headers: [X-" + level + "]\n"
+		assert.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+		_, err := reloader.Reload(context.Background())
+		assert.NoError(t, err)
+	}
+	close(stop)
+	<-done
+	assert.Equal(t, 4, reloader.Status().Version)
+}
+
+func TestReloader_RejectsRestartOnlyKeys(t *testing.T) {
+	path := writeFile(t, "app.yaml", reloadConfig)
+	reloader, registry, calls := newTestReloader(t, path)
+
+	changed := strings.Replace(reloadConfig, "  port: 7000", "  port: 7001", 1)
+	changed = strings.Replace(changed, "  level: INFO", "  level: DEBUG", 1)
+	assert.NoError(t, os.WriteFile(path, []byte(changed), 0o600))
+
+	result, err := reloader.Reload(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, configs.ReloadRejected, result.Status)
+	assert.Contains(t, result.Error, "APP_PORT")
+	// the whole reload is refused, live keys included
+	assert.Empty(t, calls)
+	assert.Equal(t, "7000", configs.App.Port)
+	assert.Equal(t, "INFO", logging.L.LogLevel)
+
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Replace(reloadConfig, "  level: INFO", "  level: LOUD", 1)), 0o600))
+	result, err = reloader.Reload(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, configs.ReloadFailed, result.Status)
+	assert.Contains(t, result.Error, `unknown log level "LOUD"`)
+	assert.Empty(t, calls)
+
+	report := registry.Run(context.Background())
+	assert.Equal(t, 1, report.Config.Version)
+	assert.Equal(t, configs.ReloadFailed, report.Config.LastReload.Status)
+}
+
+func TestReloadable_SwapsHandler(t *testing.T) {
+	header := func(value string) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			c.Set("X-Version", value)
+			return c.Next()
+		}
+	}
+	slot := middlewares.NewReloadable(header("1"))
+	app := fiber.New()
+	app.Use(slot.Handler())
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", resp.Header.Get("X-Version"))
+
+	slot.Store(header("2"))
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", resp.Header.Get("X-Version"))
+}
@@ -0,0 +1,115 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/pkg/tracing"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func setupRequestIDApp(header string) *fiber.App {
+	app := fiber.New()
+	app.Use(middlewares.RequestIDMiddleware(header))
+	app.Use(recover.New())
+	app.Use(middlewares.LoggerMiddleware(zap.NewNop().Sugar(), logging.RequestFilter{}))
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return c.SendString(c.UserContext().Value(entities.RequestId).(string))
+	})
+	app.Get("/panic", func(c *fiber.Ctx) error { panic("boom") })
+	return app
+}
+
+func TestRequestID_HonorsInboundHeader(t *testing.T) {
+	app := setupRequestIDApp("X-Correlation-ID")
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set("X-Correlation-ID", "gw-7f3a:01")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "gw-7f3a:01", resp.Header.Get("X-Request-ID"))
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "gw-7f3a:01", string(body))
+}
+
+func TestRequestID_RejectsInvalidInbound(t *testing.T) {
+	app := setupRequestIDApp("")
+
+	for _, id := range []string{"has space", "bad\"quote", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set("X-Request-ID", id)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		got := resp.Header.Get("X-Request-ID")
+		assert.NotEmpty(t, got)
+		assert.NotEqual(t, id, got)
+	}
+}
+
+func TestRequestID_OnPanicAndNotFound(t *testing.T) {
+	app := setupRequestIDApp("")
+
+	for path, status := range map[string]int{"/panic": 500, "/missing": 404} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode, path)
+		assert.Equal(t, "req-123", resp.Header.Get("X-Request-ID"), path)
+	}
+}
+
+func TestRequestID_OutlivesTheRequest(t *testing.T) {
+	app := fiber.New()
+	app.Use(middlewares.RequestIDMiddleware(""))
+	var kept []context.Context
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		kept = append(kept, c.UserContext())
+		return nil
+	})
+
+	ids := []string{"req-aaaaaaaa", "req-bbbbbbbb", "req-cccccccc"}
+	for _, id := range ids {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set("X-Request-ID", id)
+		_, err := app.Test(req)
+		assert.NoError(t, err)
+	}
+
+	// the contexts outlive their requests, the ids must not change with the next one
+	for i, ctx := range kept {
+		assert.Equal(t, ids[i], ctx.Value(entities.RequestId))
+	}
+}
+
+func TestRequestID_PropagatedOutbound(t *testing.T) {
+	ctx := context.WithValue(context.Background(), entities.RequestId, "req-456")
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Request-ID")
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := (&http.Client{Transport: tracing.Transport(nil)}).Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "req-456", received)
+
+	m := mailer.NewMemoryMailer("no-reply@example.com")
+	assert.NoError(t, m.Send(ctx, mailer.Message{To: []string{"a@b.com"}, Subject: "hi", Text: "hi"}))
+	assert.Equal(t, "req-456", m.Messages()[0].Headers["X-Request-ID"])
+}
@@ -0,0 +1,204 @@
+package user_test
+
+import (
+	adapters "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/logging"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fileLogger builds the app logger over a temporary LOG_PATH; configure may
+// adjust logging.L first.
+func fileLogger(t *testing.T, configure func()) (*zap.SugaredLogger, func() error, string) {
+	previous := *logging.L
+	t.Cleanup(func() { *logging.L = previous })
+
+	root := t.TempDir()
+	logging.L.LogPath = root
+	logging.L.LogMode = "production"
+	logging.L.LogTimezone = "UTC"
+	logging.L.LogLevel = "INFO"
+	logging.L.Sinks = nil
+	configure()
+
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	assert.NoError(t, err)
+	return logger, closeLogs, filepath.Join(root, filepath.FromSlash(time.Now().UTC().Format("2006_01/02")))
+}
+
+func readEntries(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestSampling_PerLevel(t *testing.T) {
+	logger, closeLogs, dir := fileLogger(t, func() {
+		assert.NoError(t, logging.L.Sampling.EnvDecode("INFO=3/0, DEBUG=1/0"))
+		logging.L.SamplingTick = time.Minute
+	})
+
+	for i := 0; i < 10; i++ {
+		logger.Infow("cache miss", "i", i)
+		logger.Warn("slow query")
+	}
+	logger.Info("a different message")
+	assert.NoError(t, closeLogs())
+
+	var misses, slow, other int
+	for _, entry := range readEntries(t, filepath.Join(dir, "info.json")) {
+		switch entry["message"] {
+		case "cache miss":
+			misses++
+		case "slow query":
+			slow++
+		case "a different message":
+			other++
+		}
+	}
+	assert.Equal(t, 3, misses)
+	assert.Equal(t, 10, slow) // WARNING has no rule
+	assert.Equal(t, 1, other)
+
+	var rules logging.SamplingRules
+	assert.Error(t, rules.EnvDecode("INFO"))
+	assert.Error(t, rules.EnvDecode("LOUD=1/2"))
+	assert.Error(t, rules.EnvDecode("INFO=x/2"))
+}
+
+func TestDedup_SummarizesSuppressedErrors(t *testing.T) {
+	logger, closeLogs, dir := fileLogger(t, func() {
+		logging.L.DedupWindow = 100 * time.Millisecond
+	})
+
+	for i := 0; i < 5; i++ {
+		logger.Errorw("mongo down", "attempt", i)
+	}
+	logger.Error("smtp down")
+	logger.Info("not deduplicated")
+	logger.Info("not deduplicated")
+	time.Sleep(300 * time.Millisecond)
+
+	// a new window starts, and its summary is written on close
+	logger.Error("mongo down")
+	logger.Error("mongo down")
+	logger.Error("mongo down")
+	assert.NoError(t, closeLogs())
+
+	errors := readEntries(t, filepath.Join(dir, "error.json"))
+	var messages []string
+	var suppressed []float64
+	for _, entry := range errors {
+		messages = append(messages, entry["message"].(string))
+		if n, ok := entry["suppressed"].(float64); ok {
+			suppressed = append(suppressed, n)
+			assert.Equal(t, "mongo down", entry["original_message"])
+		}
+	}
+	assert.Equal(t, []string{
+		"mongo down",
+		"smtp down",
+		"mongo down (repeated 4 more times)",
+		"mongo down",
+		"mongo down (repeated 2 more times)",
+	}, messages)
+	assert.Equal(t, []float64{4, 2}, suppressed)
+	assert.Len(t, readEntries(t, filepath.Join(dir, "info.json")), 2)
+}
+
+func TestDedup_KeepsDifferentResponseErrorsApart(t *testing.T) {
+	logger, closeLogs, dir := fileLogger(t, func() {
+		logging.L.DedupWindow = time.Minute
+	})
+
+	for i := 0; i < 3; i++ {
+		logger.Errorw("response error", "status_code", 401, "function", "Login", "error_message", "Unauthorized", "reason", fmt.Sprintf("token %d expired", i))
+		logger.Errorw("response error", "status_code", 500, "function", "GetUser", "error_message", "server selection timeout")
+	}
+	assert.NoError(t, closeLogs())
+
+	byStatus := map[float64][]string{}
+	for _, entry := range readEntries(t, filepath.Join(dir, "error.json")) {
+		status := entry["status_code"].(float64)
+		byStatus[status] = append(byStatus[status], entry["message"].(string))
+	}
+	assert.Equal(t, map[float64][]string{
+		401: {"response error", "response error (repeated 2 more times)"},
+		500: {"response error", "response error (repeated 2 more times)"},
+	}, byStatus)
+}
+
+func TestLoggerMiddleware_OnlyErrorsAndSlowRequests(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	app := fiber.New()
+	app.Use(middlewares.LoggerMiddleware(zap.New(core).Sugar(), logging.RequestFilter{OnlyErrors: true, SlowerThan: 50 * time.Millisecond}))
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return adapters.Response(c, entities.Response{Status: "OK", StatusCode: 200})
+	})
+	app.Get("/bad", func(c *fiber.Ctx) error {
+		return adapters.Response(c, entities.Response{Status: "ER", ErrorCode: "ER400", StatusCode: 400})
+	})
+	app.Get("/missing", func(c *fiber.Ctx) error { return fiber.ErrNotFound })
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		time.Sleep(80 * time.Millisecond)
+		return c.SendStatus(200)
+	})
+
+	for _, path := range []string{"/ok", "/bad", "/missing", "/slow"} {
+		_, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NoError(t, err)
+	}
+
+	var paths []string
+	for _, entry := range logs.FilterMessage("HTTP Request").All() {
+		paths = append(paths, entry.ContextMap()["path"].(string))
+	}
+	assert.Equal(t, []string{"/bad", "/missing", "/slow"}, paths)
+	assert.Zero(t, logs.FilterMessage("response success").Len())
+	assert.Equal(t, 1, logs.FilterMessage("response error").Len())
+
+	missing := logs.FilterMessage("HTTP Request").All()[1].ContextMap()
+	assert.Equal(t, int64(404), missing["status"])
+}
+
+func TestLoggerMiddleware_LogsEverythingByDefault(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	app := fiber.New()
+	app.Use(middlewares.LoggerMiddleware(zap.New(core).Sugar(), logging.RequestFilter{}))
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		return adapters.Response(c, entities.Response{Status: "OK", StatusCode: 200})
+	})
+
+	_, err := app.Test(httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, logs.FilterMessage("HTTP Request").Len())
+	assert.Equal(t, 1, logs.FilterMessage("response success").Len())
+}
@@ -0,0 +1,124 @@
+package user_test
+
+import (
+	"backend-challenge/configs"
+	"backend-challenge/configs/store"
+	"backend-challenge/utils"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func withJWTSecret(t *testing.T, secret string) {
+	withJWTConfig(t, "backend-challenge", "backend-challenge", 0)
+	previous := utils.JWT.Secret
+	utils.JWT.Secret = secret
+	// a zero grace also forgets the previous secret of the test
+	t.Cleanup(func() { utils.RotateJWTSecret(previous, 0) })
+}
+
+func TestRotateJWTSecret_GraceWindow(t *testing.T) {
+	withJWTSecret(t, "first-secret")
+
+	old, err := utils.GenerateToken("user-1", time.Hour)
+	assert.NoError(t, err)
+
+	utils.RotateJWTSecret("second-secret", time.Hour)
+	claims, err := utils.ParseToken(old)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	fresh, err := utils.GenerateToken("user-2", time.Hour)
+	assert.NoError(t, err)
+	_, err = utils.ParseToken(fresh)
+	assert.NoError(t, err)
+
+	// once the window is over only the current secret is accepted
+	utils.RotateJWTSecret("third-secret", 0)
+	_, err = utils.ParseToken(old)
+	assert.ErrorIs(t, err, utils.ErrTokenSignature)
+	_, err = utils.ParseToken(fresh)
+	assert.ErrorIs(t, err, utils.ErrTokenSignature)
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	withConfigRestored(t)
+	jwtPath := writeFile(t, "jwt", "from-secret-file\n")
+	passwordPath := writeFile(t, "mongo-password", "mongo-pass")
+	path := writeFile(t, "app.yaml", "mongo:\n  uri: mongodb://mongo:27017\n  db: appdb\n  password_file: "+passwordPath+"\n")
+
+	snapshot, err := configs.Loader{
+		Args: []string{"--config", path},
+		Env:  envconfig.MapLookuper(map[string]string{"JWT_SECRET_FILE": jwtPath}),
+	}.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "from-secret-file", utils.JWT.Secret)
+	assert.Equal(t, "mongo-pass", store.M.Password)
+
+	files := map[string]string{}
+	for _, v := range snapshot.Values {
+		if v.File != "" {
+			files[v.Key] = v.File
+		}
+	}
+	assert.Equal(t, map[string]string{"JWT_SECRET": jwtPath, "MONGO_PASSWORD": passwordPath}, files)
+
+	_, err = configs.Loader{
+		Args: []string{"--config", path},
+		Env: envconfig.MapLookuper(map[string]string{
+			"JWT_SECRET":          "inline",
+			"JWT_SECRET_FILE":     jwtPath,
+			"MONGO_USERNAME_FILE": "/does/not/exist",
+		}),
+	}.Load(context.Background())
+	var configErr *configs.ConfigError
+	if assert.ErrorAs(t, err, &configErr) {
+		assert.Len(t, configErr.Problems, 3)
+		assert.Contains(t, configErr.Problems[0], "JWT_SECRET and JWT_SECRET_FILE are both set in env")
+		assert.Contains(t, configErr.Problems[1], "MONGO_USERNAME_FILE: open /does/not/exist")
+		assert.Equal(t, "JWT_SECRET must be set", configErr.Problems[2])
+	}
+}
+
+func TestSecretWatcher_AppliesChangedFiles(t *testing.T) {
+	withConfigRestored(t)
+	jwtPath := writeFile(t, "jwt", "v1")
+	snapshot, err := configs.Loader{Env: envconfig.MapLookuper(map[string]string{
+		"JWT_SECRET_FILE": jwtPath,
+		"MONGO_URI":       "mongodb://mongo:27017",
+		"MONGO_DB":        "appdb",
+	})}.Load(context.Background())
+	assert.NoError(t, err)
+
+	var applied []string
+	fail := true
+	watcher := configs.NewSecretWatcher(snapshot, time.Minute, zap.NewNop().Sugar())
+	watcher.Handle("JWT_SECRET", func(ctx context.Context, secret string) error {
+		if fail {
+			fail = false
+			return errors.New("not now")
+		}
+		applied = append(applied, secret)
+		return nil
+	})
+	watcher.Handle("MONGO_URI", func(ctx context.Context, uri string) error {
+		t.Fatal("MONGO_URI is not read from a file")
+		return nil
+	})
+	assert.True(t, watcher.Watching())
+
+	watcher.Check(context.Background())
+	assert.Empty(t, applied)
+
+	assert.NoError(t, os.WriteFile(jwtPath, []byte("v2\n"), 0o600))
+	watcher.Check(context.Background()) // the handler fails, so it is retried
+	watcher.Check(context.Background())
+	watcher.Check(context.Background())
+	assert.Equal(t, []string{"v2"}, applied)
+}
@@ -0,0 +1,113 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type mockSessionStore struct {
+	mock.Mock
+}
+
+func (m *mockSessionStore) GetSession(sessionId string, ctx context.Context) (entities.Session, error) {
+	args := m.Called(sessionId, ctx)
+	return args.Get(0).(entities.Session), args.Error(1)
+}
+func (m *mockSessionStore) TouchSession(sessionId string, interval time.Duration, ctx context.Context) error {
+	args := m.Called(sessionId, interval, ctx)
+	return args.Error(0)
+}
+
+func setupSessionApp(store *mockSessionStore) *fiber.App {
+	app := fiber.New()
+	app.Get("/users/me", middlewares.JWTMiddleware(middlewares.JWTConfig{Sessions: store, SessionTouchInterval: time.Minute}), func(c *fiber.Ctx) error {
+		return c.SendString(c.UserContext().Value(entities.SessionIDKey).(string))
+	})
+	return app
+}
+
+func sessionToken(t *testing.T, userId string, sessionId string) string {
+	token, err := utils.GenerateTokenWithClaims(utils.Claims{UserID: userId, SessionID: sessionId}, time.Hour)
+	assert.NoError(t, err)
+	return token
+}
+
+func TestJWTMiddleware_ActiveSessionIsTouched(t *testing.T) {
+	store := new(mockSessionStore)
+	app := setupSessionApp(store)
+
+	sessionId := primitive.NewObjectID().Hex()
+	store.On("GetSession", sessionId, mock.Anything).Return(entities.Session{
+		UserID:     "user-1",
+		LastSeenAt: time.Now().Add(-10 * time.Minute),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}, nil)
+	store.On("TouchSession", sessionId, time.Minute, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionToken(t, "user-1", sessionId))
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	store.AssertExpectations(t)
+}
+
+func TestJWTMiddleware_RecentSessionIsNotTouched(t *testing.T) {
+	store := new(mockSessionStore)
+	app := setupSessionApp(store)
+
+	sessionId := primitive.NewObjectID().Hex()
+	store.On("GetSession", sessionId, mock.Anything).Return(entities.Session{
+		UserID:     "user-1",
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionToken(t, "user-1", sessionId))
+	resp, _ := app.Test(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	store.AssertNotCalled(t, "TouchSession", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestJWTMiddleware_RevokedSession(t *testing.T) {
+	store := new(mockSessionStore)
+	app := setupSessionApp(store)
+
+	revokedAt := time.Now()
+	sessionId := primitive.NewObjectID().Hex()
+	store.On("GetSession", sessionId, mock.Anything).Return(entities.Session{
+		UserID:    "user-1",
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionToken(t, "user-1", sessionId))
+	resp, _ := app.Test(req)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestJWTMiddleware_TokenWithoutSession(t *testing.T) {
+	store := new(mockSessionStore)
+	app := setupSessionApp(store)
+
+	store.On("GetSession", "", mock.Anything).Return(entities.Session{}, errors.New("invalid session ID format"))
+
+	token, _ := utils.GenerateToken("user-1", time.Hour)
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 401, resp.StatusCode)
+}
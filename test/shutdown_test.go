@@ -0,0 +1,89 @@
+package user_test
+
+import (
+	"backend-challenge/pkg/shutdown"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestShutdownManager_RunsPhasesInOrder(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	manager := shutdown.NewManager(zap.New(core).Sugar())
+
+	// the signal context is already cancelled when shutdown starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var order []string
+	manager.Add("not ready", 0, func(ctx context.Context) error {
+		order = append(order, "not ready")
+		return ctx.Err()
+	})
+	manager.Add("http", 0, func(ctx context.Context) error {
+		order = append(order, "http")
+		return errors.New("drain timed out")
+	})
+	manager.Add("mongo", time.Second, func(ctx context.Context) error {
+		order = append(order, "mongo")
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return ctx.Err()
+	})
+
+	err := manager.Shutdown(ctx)
+	assert.EqualError(t, err, "http: drain timed out")
+	// a failed phase does not stop the ones after it
+	assert.Equal(t, []string{"not ready", "http", "mongo"}, order)
+
+	assert.Len(t, logs.FilterMessage("shutdown phase done").All(), 2)
+	failed := logs.FilterMessage("shutdown phase failed").All()
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, "http", failed[0].ContextMap()["phase"])
+		assert.NotEmpty(t, failed[0].ContextMap()["duration"])
+	}
+}
+
+func TestShutdownWorkers_WaitsForWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := shutdown.NewWorkers(ctx)
+
+	stopped := make(chan struct{})
+	workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		close(stopped)
+	})
+
+	// cancelling the parent (the signal) does not stop the workers
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("worker stopped with the parent context")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.NoError(t, workers.Stop(context.Background()))
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("Stop returned before the worker finished")
+	}
+}
+
+func TestShutdownWorkers_StopDeadline(t *testing.T) {
+	workers := shutdown.NewWorkers(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	workers.Go(func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, workers.Stop(ctx), context.DeadlineExceeded)
+}
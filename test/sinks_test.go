@@ -0,0 +1,276 @@
+package user_test
+
+import (
+	"backend-challenge/pkg/logging"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+// collector is a stand-in log collector that records every line it accepts.
+type collector struct {
+	mu       sync.Mutex
+	lines    []string
+	requests int
+	failures int32 // answer 503 to this many requests first
+	status   int   // answer this status once failures run out, 0 for 204
+	hold     chan struct{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.hold != nil {
+		<-c.hold
+	}
+	body, _ := io.ReadAll(r.Body)
+	if atomic.AddInt32(&c.failures, -1) >= 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		c.lines = append(c.lines, line)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *collector) received() ([]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...), c.requests
+}
+
+func TestHTTPSink_BatchesEntries(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := logging.NewHTTPSink(logging.SinkConfig{URL: server.URL, BatchSize: 2, FlushInterval: "1h"})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		sink.Write([]byte(fmt.Sprintf(`{"n":%d}`+"\n", i)))
+	}
+	assert.NoError(t, sink.Sync())
+
+	lines, requests := c.received()
+	assert.Equal(t, []string{`{"n":0}`, `{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`}, lines)
+	assert.Equal(t, 3, requests)
+	assert.NoError(t, sink.Close())
+}
+
+func TestHTTPSink_RetriesServerErrors(t *testing.T) {
+	c := &collector{failures: 2}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := logging.NewHTTPSink(logging.SinkConfig{URL: server.URL, RetryBackoff: "1ms", MaxRetries: 3})
+	assert.NoError(t, err)
+	sink.Write([]byte(`{"msg":"kept"}`))
+	assert.NoError(t, sink.Close())
+
+	lines, _ := c.received()
+	assert.Equal(t, []string{`{"msg":"kept"}`}, lines)
+	assert.Zero(t, sink.Failed())
+}
+
+func TestHTTPSink_GivesUpOnClientErrors(t *testing.T) {
+	c := &collector{status: http.StatusBadRequest}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := logging.NewHTTPSink(logging.SinkConfig{URL: server.URL, RetryBackoff: "1ms"})
+	assert.NoError(t, err)
+	sink.Write([]byte(`{"msg":"rejected"}`))
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, int64(1), sink.Failed())
+}
+
+func TestHTTPSink_DropsWhenBufferIsFull(t *testing.T) {
+	c := &collector{hold: make(chan struct{})}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	sink, err := logging.NewHTTPSink(logging.SinkConfig{URL: server.URL, BatchSize: 1, BufferSize: 2})
+	assert.NoError(t, err)
+
+	// the first entry is in flight and held by the server, two fill the buffer
+	for i := 0; i < 10; i++ {
+		n, err := sink.Write([]byte(`{"msg":"x"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, len(`{"msg":"x"}`), n)
+		time.Sleep(time.Millisecond)
+	}
+	assert.GreaterOrEqual(t, sink.Dropped(), int64(7))
+
+	close(c.hold)
+	assert.NoError(t, sink.Close())
+	_, err = sink.Write([]byte(`{"msg":"late"}`))
+	assert.Error(t, err)
+}
+
+func TestHTTPSink_CloseGivesUpOnAStuckCollector(t *testing.T) {
+	c := &collector{hold: make(chan struct{})}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	defer close(c.hold)
+
+	sink, err := logging.NewHTTPSink(logging.SinkConfig{URL: server.URL, BatchSize: 1, CloseTimeout: "100ms"})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		sink.Write([]byte(`{"msg":"stuck"}`))
+	}
+
+	start := time.Now()
+	assert.NoError(t, sink.Close())
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int64(5), sink.Failed())
+}
+
+func TestSyslogSink_RFC5424(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	writer, err := logging.NewSyslogWriter(logging.SinkConfig{Address: "udp://" + conn.LocalAddr().String(), Facility: 1, AppName: "backend-challenge"})
+	assert.NoError(t, err)
+	defer writer.Close()
+
+	previous := *logging.L
+	t.Cleanup(func() { *logging.L = previous })
+	logging.L.LogTimezone = "UTC"
+	logging.L.LogMode = "production"
+	logging.L.LogLevel = "INFO"
+	logging.L.Sinks = logging.Sinks{{Type: "syslog", Address: "udp://" + conn.LocalAddr().String(), AppName: "backend-challenge", Level: "ERROR"}}
+
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	assert.NoError(t, err)
+	logger.Info("not shipped")
+	logger.Errorw("mongo down", "attempt", 2)
+	assert.NoError(t, closeLogs())
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	msg := string(buf[:n])
+	// facility user (1) * 8 + severity error (3)
+	assert.True(t, strings.HasPrefix(msg, "<11>1 "), msg)
+	assert.Contains(t, msg, " backend-challenge ")
+	assert.Contains(t, msg, `"message":"mongo down"`)
+	assert.NotContains(t, msg, "not shipped")
+}
+
+func TestSyslogWriter_TCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var length int
+		reader := bufio.NewReader(conn)
+		fmt.Fscanf(reader, "%d ", &length)
+		frame := make([]byte, length)
+		io.ReadFull(reader, frame)
+		received <- string(frame)
+	}()
+
+	writer, err := logging.NewSyslogWriter(logging.SinkConfig{Address: "tcp://" + listener.Addr().String(), AppName: "app"})
+	assert.NoError(t, err)
+	defer writer.Close()
+	assert.NoError(t, writer.WriteMessage(6, time.Now(), []byte(`{"message":"hi"}`+"\n")))
+
+	select {
+	case frame := <-received:
+		assert.True(t, strings.HasPrefix(frame, "<8>1 "), frame)
+		assert.True(t, strings.HasSuffix(frame, `{"message":"hi"}`), frame)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no syslog frame received")
+	}
+}
+
+func TestSyslogWriter_DoesNotBlockWhileDisconnected(t *testing.T) {
+	// nothing listens here any more
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	writer, err := logging.NewSyslogWriter(logging.SinkConfig{Address: "tcp://" + address, BufferSize: 10})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, writer.WriteMessage(3, time.Now(), []byte(`{"message":"mongo down"}`)))
+	}
+	assert.NoError(t, writer.Sync())
+	assert.NoError(t, writer.Close())
+	assert.Less(t, time.Since(start), time.Second)
+
+	// one dial failed, the rest waited for the backoff or found the buffer full
+	assert.Equal(t, int64(1000), writer.Dropped())
+	assert.Error(t, writer.WriteMessage(3, time.Now(), []byte(`{"message":"late"}`)))
+}
+
+func TestLogSinks_FromEnvironment(t *testing.T) {
+	previous := *logging.L
+	t.Cleanup(func() { *logging.L = previous })
+
+	root := t.TempDir()
+	err := envconfig.ProcessWith(context.Background(), &envconfig.Config{
+		Target: logging.L,
+		Lookuper: envconfig.MapLookuper(map[string]string{
+			"LOG_PATH":  root,
+			"LOG_MODE":  "production",
+			"LOG_SINKS": `[{"type":"stderr","encoder":"console","level":"WARNING"}]`,
+		}),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, logging.Sinks{{Type: "stderr", Encoder: "console", Level: "WARNING"}}, logging.L.Sinks)
+
+	// container mode: nothing is written under LOG_PATH
+	logger, closeLogs, err := logging.NewLogger(logging.L)
+	assert.NoError(t, err)
+	logger.Warn("to stderr only")
+	assert.NoError(t, closeLogs())
+	entries, _ := os.ReadDir(root)
+	assert.Empty(t, entries)
+
+	for _, sinks := range []logging.Sinks{
+		{{Type: "kafka"}},
+		{{Type: "stdout", Encoder: "xml"}},
+		{{Type: "http"}},
+		{{Type: "syslog", Address: "localhost:514"}},
+	} {
+		logging.L.Sinks = sinks
+		_, _, err := logging.NewLogger(logging.L)
+		assert.Error(t, err)
+	}
+
+	var invalid logging.Sinks
+	assert.Error(t, invalid.EnvDecode(`{"type":"stderr"}`))
+}
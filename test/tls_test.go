@@ -0,0 +1,268 @@
+package user_test
+
+import (
+	"backend-challenge/configs"
+	"backend-challenge/entities"
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/certs"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA when
+// parent is nil.
+func newTestCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"backend-challenge"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	assert.NoError(t, err)
+	return pair
+}
+
+func writeCertFiles(t *testing.T, dir string, cert *testCert) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	assert.NoError(t, os.WriteFile(certFile, cert.certPEM, 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, cert.keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// serveTLS starts an app behind certs.C's listener config. The route returns
+// the principal JWTMiddleware resolved.
+func serveTLS(t *testing.T, reloader *certs.Reloader) string {
+	tlsConfig, err := certs.C.ServerConfig(reloader)
+	assert.NoError(t, err)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	jwtConfig := middlewares.JWTConfig{}
+	if certs.C.ClientCAFile != "" {
+		jwtConfig.ClientCerts = &middlewares.ClientCertAuth{Scopes: certs.C.ClientScopes}
+	}
+	app.Get("/whoami", middlewares.JWTMiddleware(jwtConfig), func(c *fiber.Ctx) error {
+		principal, _ := entities.PrincipalFromContext(c.UserContext())
+		return c.JSON(principal)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(tls.NewListener(ln, tlsConfig))
+	t.Cleanup(func() { app.Shutdown() })
+	return "https://" + ln.Addr().String()
+}
+
+func tlsClient(t *testing.T, ca, client *testCert) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: pool}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{client.tlsCertificate(t)}
+	}
+	// a new connection per request, so every request sees the current certificate
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+}
+
+func TestTLS_ReloadsCertificate(t *testing.T) {
+	withConfigRestored(t)
+	ca := newTestCert(t, "test-ca", 1, nil)
+	certFile, keyFile := writeCertFiles(t, t.TempDir(), newTestCert(t, "server", 10, ca))
+	certs.C.CertFile, certs.C.KeyFile = certFile, keyFile
+
+	reloader, err := certs.NewReloader(certFile, keyFile, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	url := serveTLS(t, reloader)
+	client := tlsClient(t, ca, nil)
+
+	resp, err := client.Get(url + "/whoami")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int64(10), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+	// no client certificate and no token
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	changed, err := reloader.Check()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// a broken pair is refused and the current certificate kept
+	assert.NoError(t, os.WriteFile(certFile, newTestCert(t, "server", 11, ca).certPEM, 0o600))
+	_, err = reloader.Check()
+	assert.Error(t, err)
+	assert.Equal(t, int64(10), reloader.Certificate().SerialNumber.Int64())
+
+	writeCertFiles(t, filepath.Dir(certFile), newTestCert(t, "server", 12, ca))
+	changed, err = reloader.Check()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	resp, err = client.Get(url + "/whoami")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int64(12), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+	assert.NoError(t, reloader.Expiry(context.Background()))
+}
+
+func TestTLS_ClientCertificatePrincipal(t *testing.T) {
+	withConfigRestored(t)
+	ca := newTestCert(t, "test-ca", 1, nil)
+	dir := t.TempDir()
+	certFile, keyFile := writeCertFiles(t, dir, newTestCert(t, "server", 10, ca))
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	certs.C.CertFile, certs.C.KeyFile, certs.C.ClientCAFile = certFile, keyFile, caFile
+	certs.C.ClientAuth = certs.ClientAuthRequire
+	certs.C.MinVersion = "1.3"
+	certs.C.ClientScopes = certs.ClientScopes{"billing": {entities.ScopeUsersRead}}
+
+	reloader, err := certs.NewReloader(certFile, keyFile, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	url := serveTLS(t, reloader)
+
+	resp, err := tlsClient(t, ca, newTestCert(t, "billing", 20, ca)).Get(url + "/whoami")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+	var principal entities.Principal
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+	assert.Equal(t, entities.PrincipalService, principal.Type)
+	assert.Equal(t, "billing", principal.ID)
+	assert.Equal(t, "CN=billing,O=backend-challenge", principal.Name)
+	assert.Equal(t, []string{entities.ScopeUsersRead}, principal.Scopes)
+
+	// a certificate from another CA, or none, fails the handshake
+	_, err = tlsClient(t, ca, newTestCert(t, "billing", 21, newTestCert(t, "other-ca", 2, nil))).Get(url + "/whoami")
+	assert.Error(t, err)
+	_, err = tlsClient(t, ca, nil).Get(url + "/whoami")
+	assert.Error(t, err)
+}
+
+func TestTLS_OptionalClientCertificate(t *testing.T) {
+	withConfigRestored(t)
+	ca := newTestCert(t, "test-ca", 1, nil)
+	dir := t.TempDir()
+	certFile, keyFile := writeCertFiles(t, dir, newTestCert(t, "server", 10, ca))
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	certs.C.CertFile, certs.C.KeyFile, certs.C.ClientCAFile = certFile, keyFile, caFile
+	certs.C.ClientAuth = certs.ClientAuthOptional
+
+	reloader, err := certs.NewReloader(certFile, keyFile, zap.NewNop().Sugar())
+	assert.NoError(t, err)
+	url := serveTLS(t, reloader)
+
+	resp, err := tlsClient(t, ca, nil).Get(url + "/whoami")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = tlsClient(t, ca, newTestCert(t, "reports", 20, ca)).Get(url + "/whoami")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var principal entities.Principal
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+	assert.Equal(t, "reports", principal.ID)
+	assert.Empty(t, principal.Scopes)
+}
+
+func TestLoad_TLSSettings(t *testing.T) {
+	withConfigRestored(t)
+	base := map[string]string{"JWT_SECRET": "secret", "MONGO_URI": "mongodb://mongo:27017", "MONGO_DB": "appdb"}
+	env := func(extra map[string]string) envconfig.Lookuper {
+		values := map[string]string{}
+		for k, v := range base {
+			values[k] = v
+		}
+		for k, v := range extra {
+			values[k] = v
+		}
+		return envconfig.MapLookuper(values)
+	}
+
+	_, err := configs.Loader{Env: env(map[string]string{
+		"TLS_CERT_FILE":     "/tls/tls.crt",
+		"TLS_KEY_FILE":      "/tls/tls.key",
+		"TLS_CIPHER_SUITES": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_CLIENT_SCOPES": "billing=users:read users:write,reports=users:read",
+	})}.Load(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, certs.C.Enabled())
+	assert.Equal(t, certs.ClientScopes{"billing": {"users:read", "users:write"}, "reports": {"users:read"}}, certs.C.ClientScopes)
+
+	path := writeFile(t, "app.yaml", "tls:\n  client_scopes:\n    billing: [users:read]\n")
+	_, err = configs.Loader{Args: []string{"--config", path}, Env: env(nil)}.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, certs.ClientScopes{"billing": {"users:read"}}, certs.C.ClientScopes)
+
+	_, err = configs.Loader{Env: env(map[string]string{
+		"TLS_CERT_FILE":      "/tls/tls.crt",
+		"TLS_MIN_VERSION":    "1.4",
+		"TLS_CIPHER_SUITES":  "TLS_RSA_WITH_RC4_128_SHA",
+		"TLS_CLIENT_AUTH":    "sometimes",
+		"TLS_CLIENT_CA_FILE": "/tls/ca.crt",
+	})}.Load(context.Background())
+	var configErr *configs.ConfigError
+	if assert.ErrorAs(t, err, &configErr) {
+		assert.ElementsMatch(t, []string{
+			"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+			`TLS_MIN_VERSION "1.4" is not 1.0, 1.1, 1.2 or 1.3`,
+			`TLS_CIPHER_SUITES: unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`,
+			`TLS_CLIENT_AUTH "sometimes" is not require or optional`,
+		}, configErr.Problems)
+	}
+}
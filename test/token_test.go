@@ -0,0 +1,108 @@
+package user_test
+
+import (
+	"backend-challenge/middlewares"
+	"backend-challenge/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func withJWTConfig(t *testing.T, issuer, audience string, leeway time.Duration) {
+	previous := *utils.JWT
+	utils.JWT.Issuer, utils.JWT.Audience, utils.JWT.Leeway = issuer, audience, leeway
+	t.Cleanup(func() { *utils.JWT = previous })
+}
+
+func signClaims(t *testing.T, claims utils.Claims, key string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestParseToken_RegisteredClaims(t *testing.T) {
+	withJWTConfig(t, "backend-staging", "backend-api", 0)
+
+	token, err := utils.GenerateToken("user-1", time.Hour)
+	assert.NoError(t, err)
+	claims, err := utils.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "backend-staging", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"backend-api"}, claims.Audience)
+	assert.NotNil(t, claims.NotBefore)
+
+	// a token minted by another environment sharing the secret
+	utils.JWT.Issuer = "backend-production"
+	_, err = utils.ParseToken(token)
+	assert.ErrorIs(t, err, utils.ErrTokenIssuer)
+
+	utils.JWT.Issuer = "backend-staging"
+	utils.JWT.Audience = "other-api"
+	_, err = utils.ParseToken(token)
+	assert.ErrorIs(t, err, utils.ErrTokenAudience)
+}
+
+func TestParseToken_DistinctErrors(t *testing.T) {
+	withJWTConfig(t, "", "", 0)
+	secret := utils.JWT.Secret
+	now := time.Now()
+
+	expired, _ := utils.GenerateToken("user-1", -time.Minute)
+	_, err := utils.ParseToken(expired)
+	assert.ErrorIs(t, err, utils.ErrTokenExpired)
+
+	future := utils.Claims{UserID: "user-1", RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "user-1",
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now.Add(time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Hour)),
+	}}
+	_, err = utils.ParseToken(signClaims(t, future, secret))
+	assert.ErrorIs(t, err, utils.ErrTokenNotValidYet)
+
+	_, err = utils.ParseToken(signClaims(t, future, secret+"-wrong"))
+	assert.ErrorIs(t, err, utils.ErrTokenSignature)
+
+	noExpiry := utils.Claims{UserID: "user-1", RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"}}
+	_, err = utils.ParseToken(signClaims(t, noExpiry, secret))
+	assert.ErrorIs(t, err, utils.ErrTokenClaims)
+
+	_, err = utils.ParseToken("not-a-token")
+	assert.ErrorIs(t, err, utils.ErrTokenMalformed)
+
+	action, _, _ := utils.GenerateActionToken("user-1", utils.PurposeVerifyEmail, time.Hour)
+	_, err = utils.ParseToken(action)
+	assert.ErrorIs(t, err, utils.ErrTokenPurpose)
+}
+
+func TestParseToken_Leeway(t *testing.T) {
+	withJWTConfig(t, "", "", time.Minute)
+
+	// expired 30 seconds ago, still inside the leeway
+	token, _ := utils.GenerateToken("user-1", -30*time.Second)
+	_, err := utils.ParseToken(token)
+	assert.NoError(t, err)
+}
+
+func TestJWTMiddleware_DoesNotLeakReason(t *testing.T) {
+	withJWTConfig(t, "", "", 0)
+	app := fiber.New()
+	app.Get("/protected", middlewares.JWTMiddleware(), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+	expired, _ := utils.GenerateToken("user-1", -time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	resp, _ := app.Test(req)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, "Unauthorized: invalid token", body["errorMessage"])
+}
@@ -0,0 +1,75 @@
+package user_test
+
+import (
+	"backend-challenge/middlewares"
+	"backend-challenge/pkg/logging"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func withTracer(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return exporter
+}
+
+func TestTracingMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	exporter := withTracer(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	app := fiber.New()
+	app.Use(middlewares.TracingMiddleware())
+	app.Use(middlewares.LoggerMiddleware(zap.New(core).Sugar(), logging.RequestFilter{}))
+	app.Get("/users/:id", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+	req := httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "GET /users/:id", span.Name)
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	}
+
+	entries := logs.FilterMessage("HTTP Request").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries[0].ContextMap()["trace_id"])
+	}
+}
+
+func TestFromContext_WithoutSpan(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := logging.WithLogger(context.Background(), zap.New(core).Sugar())
+
+	logging.FromContext(ctx).Info("no span")
+	entries := logs.All()
+	if assert.Len(t, entries, 1) {
+		assert.NotContains(t, entries[0].ContextMap(), "trace_id")
+	}
+}
@@ -0,0 +1,125 @@
+package user_test
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/usecases"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockVerificationRepo struct {
+	mock.Mock
+}
+
+func (m *mockVerificationRepo) GetUserByEmail(email string, ctx context.Context) (entities.User, error) {
+	args := m.Called(email, ctx)
+	return args.Get(0).(entities.User), args.Error(1)
+}
+func (m *mockVerificationRepo) CreateEmailVerification(v entities.EmailVerification, ctx context.Context) error {
+	args := m.Called(v, ctx)
+	return args.Error(0)
+}
+func (m *mockVerificationRepo) ConsumeEmailVerification(tokenId string, ctx context.Context) (entities.EmailVerification, error) {
+	args := m.Called(tokenId, ctx)
+	return args.Get(0).(entities.EmailVerification), args.Error(1)
+}
+func (m *mockVerificationRepo) InvalidateEmailVerifications(userId string, ctx context.Context) error {
+	args := m.Called(userId, ctx)
+	return args.Error(0)
+}
+func (m *mockVerificationRepo) MarkEmailVerified(userId string, email string, ctx context.Context) error {
+	args := m.Called(userId, email, ctx)
+	return args.Error(0)
+}
+
+func setupVerificationApp(repo *mockUserRepo, verifyRepo *mockVerificationRepo, mail mailer.Mailer, requireVerified bool) *fiber.App {
+	h := usecases.NewHttpUser(validator.New(), repo, usecases.WithEmailVerification(verifyRepo, mail, usecases.VerificationConfig{
+		TTL:             time.Hour,
+		RequireVerified: requireVerified,
+		LinkURL:         "http://localhost/verify",
+	}))
+	app := setupTestApp(h)
+	app.Post("/auth/verify-email", h.VerifyEmail)
+	app.Post("/auth/resend-verification", h.ResendVerification)
+	return app
+}
+
+func TestRegisterSendsVerificationEmail(t *testing.T) {
+	repo := new(mockUserRepo)
+	verifyRepo := new(mockVerificationRepo)
+	mail := mailer.NewMemoryMailer("test@local")
+	app := setupVerificationApp(repo, verifyRepo, mail, false)
+
+	var stored entities.EmailVerification
+	repo.On("CheckDuplicateUser", "tee@email.com", mock.Anything).Return(nil)
+	repo.On("Register", mock.MatchedBy(func(u entities.User) bool { return !u.EmailVerified }), mock.Anything).Return(nil)
+	verifyRepo.On("CreateEmailVerification", mock.AnythingOfType("entities.EmailVerification"), mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(entities.EmailVerification) }).Return(nil)
+
+	body := `{"name":"Tee","email":"tee@email.com","password":"123456","emailVerified":true}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	messages := mail.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, []string{"tee@email.com"}, messages[0].To)
+		assert.Contains(t, messages[0].HTML, "http://localhost/verify?token=")
+		assert.NotEmpty(t, messages[0].Text)
+	}
+
+	// redeem the token from the email
+	token := regexp.MustCompile(`token=([^"&\s]+)`).FindStringSubmatch(messages[0].Text)[1]
+	verifyRepo.On("ConsumeEmailVerification", stored.ID, mock.Anything).Return(stored, nil)
+	verifyRepo.On("MarkEmailVerified", stored.UserID, "tee@email.com", mock.Anything).Return(nil)
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(`{"token":"`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	repo.AssertExpectations(t)
+	verifyRepo.AssertExpectations(t)
+}
+
+func TestVerifyEmail_UsedToken(t *testing.T) {
+	repo := new(mockUserRepo)
+	verifyRepo := new(mockVerificationRepo)
+	app := setupVerificationApp(repo, verifyRepo, mailer.NewMemoryMailer("test@local"), false)
+
+	verifyRepo.On("ConsumeEmailVerification", mock.Anything, mock.Anything).Return(entities.EmailVerification{}, errors.New("used"))
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/verify-email", strings.NewReader(`{"token":"not-a-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestLogin_RequiresVerifiedEmail(t *testing.T) {
+	repo := new(mockUserRepo)
+	verifyRepo := new(mockVerificationRepo)
+	app := setupVerificationApp(repo, verifyRepo, mailer.NewMemoryMailer("test@local"), true)
+
+	repo.On("Login", mock.AnythingOfType("entities.Login"), mock.Anything).Return("userid123", nil)
+	repo.On("GetUser", "userid123", mock.Anything).Return(entities.User{Email: "a@b.com"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"a@b.com","password":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, 403, resp.StatusCode)
+}
@@ -0,0 +1,14 @@
+package usecases
+
+import (
+	"context"
+	"time"
+)
+
+type accountRepository interface {
+	auditRepository
+	UpdateProfile(userId string, name string, email string, ctx context.Context) error
+	ScheduleDeletion(userId string, at time.Time, ctx context.Context) error
+	CancelDeletion(userId string, ctx context.Context) error
+	RevokeUserSessions(userId string, ctx context.Context) error
+}
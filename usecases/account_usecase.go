@@ -0,0 +1,161 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/utils"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AccountConfig struct {
+	// DeletionGracePeriod delays DELETE /users/me so the user can cancel it.
+	// Zero deletes the account immediately.
+	DeletionGracePeriod time.Duration
+}
+
+type account struct {
+	repo accountRepository
+	cfg  AccountConfig
+}
+
+func WithAccount(repo accountRepository, cfg AccountConfig) Option {
+	return func(uc *HttpUser) {
+		uc.account = &account{repo: repo, cfg: cfg}
+	}
+}
+
+func (uc *HttpUser) GetMe(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "GetMe"})
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "GetMe"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: entities.NewUserResponse(user), StatusCode: 200}, map[string]interface{}{"function": "GetMe"})
+}
+
+func (uc *HttpUser) UpdateMe(c *fiber.Ctx) error {
+	if uc.account == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Self-service is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "UpdateMe"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "UpdateMe"})
+	}
+
+	var bodyRequest entities.UpdateMeRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateMe"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateMe"})
+		}
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "UpdateMe"})
+	}
+
+	emailChanged := bodyRequest.Email != "" && !strings.EqualFold(bodyRequest.Email, user.Email)
+	if emailChanged {
+		if !utils.HashEqual(user.Password, utils.Hash(bodyRequest.CurrentPassword)) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Current password is required to change the email", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateMe"})
+		}
+		if err := uc.repo.CheckDuplicateUser(bodyRequest.Email, c.UserContext()); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateMe"})
+		}
+	}
+
+	email := ""
+	if emailChanged {
+		email = bodyRequest.Email
+	}
+	if bodyRequest.Name != "" || emailChanged {
+		if err := uc.account.repo.UpdateProfile(userId, bodyRequest.Name, email, c.UserContext()); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateMe"})
+		}
+	}
+
+	message := "Update success"
+	if emailChanged {
+		recordAudit(c, uc.account.repo, userId, entities.AuditEmailChanged, map[string]interface{}{"from": user.Email, "to": bodyRequest.Email})
+
+		if uc.verification != nil {
+			user.Email = bodyRequest.Email
+			if err := uc.verification.repo.InvalidateEmailVerifications(userId, c.UserContext()); err != nil {
+				logging.FromContext(c.UserContext()).Errorw("failed to invalidate verification tokens", "error", err)
+			}
+			if err := uc.sendVerification(c.UserContext(), user); err != nil {
+				logging.FromContext(c.UserContext()).Errorw("failed to send verification email", "error", err)
+			}
+			message = "Update success, please verify the new email address"
+		}
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Message: message, StatusCode: 200}, map[string]interface{}{"function": "UpdateMe"})
+}
+
+func (uc *HttpUser) DeleteMe(c *fiber.Ctx) error {
+	if uc.account == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Self-service is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "DeleteMe"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "DeleteMe"})
+	}
+
+	if uc.account.cfg.DeletionGracePeriod <= 0 {
+		if err := uc.repo.DeleteUser(userId, c.UserContext()); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "DeleteMe"})
+		}
+		if err := uc.account.repo.RevokeUserSessions(userId, c.UserContext()); err != nil {
+			logging.FromContext(c.UserContext()).Errorw("failed to revoke sessions", "error", err)
+		}
+		recordAudit(c, uc.account.repo, userId, entities.AuditAccountDeleted, nil)
+		return handlers.Response(c, entities.Response{Status: "OK", Message: "Delete success", StatusCode: 200}, map[string]interface{}{"function": "DeleteMe"})
+	}
+
+	scheduledAt := time.Now().Add(uc.account.cfg.DeletionGracePeriod)
+	if err := uc.account.repo.ScheduleDeletion(userId, scheduledAt, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "DeleteMe"})
+	}
+
+	recordAudit(c, uc.account.repo, userId, entities.AuditDeletionScheduled, map[string]interface{}{"scheduledAt": scheduledAt})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Account deletion scheduled", StatusCode: 200, Data: map[string]interface{}{
+		"deletionScheduledAt": scheduledAt,
+	}}, map[string]interface{}{"function": "DeleteMe"})
+}
+
+func (uc *HttpUser) CancelDeleteMe(c *fiber.Ctx) error {
+	if uc.account == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Self-service is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "CancelDeleteMe"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "CancelDeleteMe"})
+	}
+
+	if err := uc.account.repo.CancelDeletion(userId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CancelDeleteMe"})
+	}
+
+	recordAudit(c, uc.account.repo, userId, entities.AuditDeletionCancelled, nil)
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Account deletion cancelled", StatusCode: 200}, map[string]interface{}{"function": "CancelDeleteMe"})
+}
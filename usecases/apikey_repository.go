@@ -0,0 +1,15 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+type apiKeyRepository interface {
+	auditRepository
+	CreateAPIKey(key entities.APIKey, ctx context.Context) error
+	ListAPIKeys(ctx context.Context) ([]entities.APIKey, error)
+	GetAPIKey(keyId string, ctx context.Context) (entities.APIKey, error)
+	UpdateAPIKey(keyId string, data entities.UpdateAPIKeyRequest, ctx context.Context) error
+	RevokeAPIKey(keyId string, ctx context.Context) error
+}
@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/utils"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HttpAPIKey struct {
+	repo     apiKeyRepository
+	validate *validator.Validate
+}
+
+func NewHttpAPIKey(validate *validator.Validate, repo apiKeyRepository) HttpAPIKey {
+	return HttpAPIKey{validate: validate, repo: repo}
+}
+
+func (uc *HttpAPIKey) Create(c *fiber.Ctx) error {
+	var bodyRequest entities.CreateAPIKeyRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateAPIKey"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateAPIKey"})
+		}
+	}
+	if bodyRequest.ExpiresAt != nil && !bodyRequest.ExpiresAt.After(time.Now()) {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "expiresAt must be in the future", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateAPIKey"})
+	}
+
+	secret, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "CreateAPIKey"})
+	}
+
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	key := entities.APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      bodyRequest.Name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    bodyRequest.Scopes,
+		ExpiresAt: bodyRequest.ExpiresAt,
+		CreatedBy: principal.Type + ":" + principal.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := uc.repo.CreateAPIKey(key, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "CreateAPIKey"})
+	}
+
+	recordAudit(c, uc.repo, principal.ID, entities.AuditAPIKeyCreated, map[string]interface{}{"apiKeyId": key.ID.Hex(), "scopes": key.Scopes})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "API key created, the key is shown only once", StatusCode: 200, Data: map[string]interface{}{
+		"key":    secret,
+		"apiKey": key,
+	}}, map[string]interface{}{"function": "CreateAPIKey"})
+}
+
+func (uc *HttpAPIKey) GetAll(c *fiber.Ctx) error {
+	keys, err := uc.repo.ListAPIKeys(c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "GetAllAPIKeys"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: keys, StatusCode: 200}, map[string]interface{}{"function": "GetAllAPIKeys"})
+}
+
+func (uc *HttpAPIKey) Get(c *fiber.Ctx) error {
+	key, err := uc.repo.GetAPIKey(c.Params("id"), c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "GetAPIKey"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: key, StatusCode: 200}, map[string]interface{}{"function": "GetAPIKey"})
+}
+
+func (uc *HttpAPIKey) Update(c *fiber.Ctx) error {
+	keyId := c.Params("id")
+	var bodyRequest entities.UpdateAPIKeyRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateAPIKey"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "UpdateAPIKey"})
+		}
+	}
+
+	if err := uc.repo.UpdateAPIKey(keyId, bodyRequest, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "UpdateAPIKey"})
+	}
+
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	recordAudit(c, uc.repo, principal.ID, entities.AuditAPIKeyUpdated, map[string]interface{}{"apiKeyId": keyId})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Update success", StatusCode: 200}, map[string]interface{}{"function": "UpdateAPIKey"})
+}
+
+func (uc *HttpAPIKey) Delete(c *fiber.Ctx) error {
+	keyId := c.Params("id")
+	if err := uc.repo.RevokeAPIKey(keyId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "DeleteAPIKey"})
+	}
+
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	recordAudit(c, uc.repo, principal.ID, entities.AuditAPIKeyRevoked, map[string]interface{}{"apiKeyId": keyId})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "API key revoked", StatusCode: 200}, map[string]interface{}{"function": "DeleteAPIKey"})
+}
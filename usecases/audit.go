@@ -0,0 +1,37 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordAudit stores an audit event for the current request. Failures are
+// logged only, the action itself has already happened.
+func recordAudit(c *fiber.Ctx, repo auditRepository, userId string, action string, metadata map[string]interface{}) {
+	ctx := c.UserContext()
+	event := entities.AuditEvent{
+		UserID:    userId,
+		Action:    action,
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if requestId := ctx.Value(entities.RequestId); requestId != nil {
+		event.RequestID = fmt.Sprintf("%s", requestId)
+	}
+
+	if err := repo.RecordAudit(event, ctx); err != nil {
+		logging.FromContext(ctx).Errorw("failed to record audit event", "action", action, "error", err)
+	}
+}
+
+// currentUserID returns the user id placed in the context by JWTMiddleware.
+func currentUserID(c *fiber.Ctx) (string, bool) {
+	userId, ok := c.UserContext().Value(entities.UserIDKey).(string)
+	return userId, ok && userId != ""
+}
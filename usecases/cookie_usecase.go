@@ -0,0 +1,99 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CookieConfig struct {
+	Name     string
+	CSRFName string
+	Domain   string
+	Secure   bool
+	SameSite string
+}
+
+type cookieSession struct {
+	cfg CookieConfig
+}
+
+// WithCookieSession makes login set the access token in an HttpOnly cookie
+// instead of returning it, for browser clients.
+func WithCookieSession(cfg CookieConfig) Option {
+	return func(uc *HttpUser) {
+		uc.cookies = &cookieSession{cfg: cfg}
+	}
+}
+
+// setAuthCookies sets the access token cookie and a CSRF cookie readable by
+// scripts, and returns the CSRF token.
+func (uc *HttpUser) setAuthCookies(c *fiber.Ctx, token string, expiry time.Duration) (string, error) {
+	csrf, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := uc.cookies.cfg
+	expires := time.Now().Add(expiry)
+	c.Cookie(&fiber.Cookie{
+		Name:     cfg.Name,
+		Value:    token,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  expires,
+		Secure:   cfg.Secure,
+		HTTPOnly: true,
+		SameSite: cfg.SameSite,
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     cfg.CSRFName,
+		Value:    csrf,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  expires,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
+	})
+
+	return csrf, nil
+}
+
+func (uc *HttpUser) clearAuthCookies(c *fiber.Ctx) {
+	cfg := uc.cookies.cfg
+	for _, name := range []string{cfg.Name, cfg.CSRFName} {
+		c.Cookie(&fiber.Cookie{
+			Name:     name,
+			Path:     "/",
+			Domain:   cfg.Domain,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   cfg.Secure,
+			HTTPOnly: name == cfg.Name,
+			SameSite: cfg.SameSite,
+		})
+	}
+}
+
+// Logout ends the current session and clears the auth cookies.
+func (uc *HttpUser) Logout(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "Logout"})
+	}
+
+	if sessionId, _ := c.UserContext().Value(entities.SessionIDKey).(string); uc.sessions != nil && sessionId != "" {
+		if err := uc.sessions.repo.RevokeSession(userId, sessionId, c.UserContext()); err != nil {
+			logging.FromContext(c.UserContext()).Warnw("failed to revoke session on logout", "error", err)
+		}
+	}
+	if uc.cookies != nil {
+		uc.clearAuthCookies(c)
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Logged out", StatusCode: 200}, map[string]interface{}{"function": "Logout"})
+}
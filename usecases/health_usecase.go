@@ -0,0 +1,49 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HttpHealth struct {
+	registry *health.Registry
+}
+
+func NewHttpHealth(registry *health.Registry) HttpHealth {
+	return HttpHealth{registry: registry}
+}
+
+// Live only tells the orchestrator the process is serving; dependencies are
+// left to Ready so a Mongo outage does not get the pod restarted.
+func (uc *HttpHealth) Live(c *fiber.Ctx) error {
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Alive", StatusCode: 200}, map[string]interface{}{"function": "Live"})
+}
+
+// Ready runs every registered check and answers 503 when one fails or the
+// app is shutting down. It is public, so the body only carries the overall
+// status; the per-check report is on the admin listener's Health.
+func (uc *HttpHealth) Ready(c *fiber.Ctx) error {
+	report := uc.registry.Run(c.UserContext())
+	data := map[string]interface{}{"status": report.Status}
+	if !report.Ready() {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Not ready", ErrorCode: "ER503", StatusCode: 503, Data: data}, map[string]interface{}{"function": "Ready"})
+	}
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Ready", StatusCode: 200, Data: data}, map[string]interface{}{"function": "Ready"})
+}
+
+// Health answers like Ready with the per-check report as the body.
+func (uc *HttpHealth) Health(c *fiber.Ctx) error {
+	report := uc.registry.Run(c.UserContext())
+	if !report.Ready() {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Not ready", ErrorCode: "ER503", StatusCode: 503, Data: report}, map[string]interface{}{"function": "Health"})
+	}
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Ready", StatusCode: 200, Data: report}, map[string]interface{}{"function": "Health"})
+}
+
+// Jobs lists the background workers and whether they are running.
+func (uc *HttpHealth) Jobs(c *fiber.Ctx) error {
+	return handlers.Response(c, entities.Response{Status: "OK", StatusCode: 200, Data: uc.registry.Workers()}, map[string]interface{}{"function": "Jobs"})
+}
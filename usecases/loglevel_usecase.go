@@ -0,0 +1,66 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type HttpLogLevel struct {
+	levels   *logging.LevelRegistry
+	audit    auditRepository
+	validate *validator.Validate
+}
+
+func NewHttpLogLevel(validate *validator.Validate, levels *logging.LevelRegistry, audit auditRepository) HttpLogLevel {
+	return HttpLogLevel{validate: validate, levels: levels, audit: audit}
+}
+
+func (uc *HttpLogLevel) GetAll(c *fiber.Ctx) error {
+	return handlers.Response(c, entities.Response{Status: "OK", Data: uc.levels.List(), StatusCode: 200}, map[string]interface{}{"function": "GetLogLevels"})
+}
+
+func (uc *HttpLogLevel) Set(c *fiber.Ctx) error {
+	var bodyRequest entities.SetLogLevelRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "SetLogLevel"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "SetLogLevel"})
+		}
+	}
+	level, err := logging.ParseLevel(bodyRequest.Level)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "SetLogLevel"})
+	}
+	var ttl time.Duration
+	if bodyRequest.TTL != "" {
+		ttl, err = time.ParseDuration(bodyRequest.TTL)
+		if err != nil || ttl <= 0 {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "ttl must be a positive duration such as 15m", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "SetLogLevel"})
+		}
+	}
+
+	uc.levels.Set(bodyRequest.Logger, level, ttl)
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	recordAudit(c, uc.audit, principal.ID, entities.AuditLogLevelChanged, map[string]interface{}{"logger": bodyRequest.Logger, "level": logging.LevelName(level), "ttl": ttl.String()})
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: uc.levels.List(), StatusCode: 200}, map[string]interface{}{"function": "SetLogLevel"})
+}
+
+// Reset drops the override of ?logger=, or restores the default level when
+// it is not given.
+func (uc *HttpLogLevel) Reset(c *fiber.Ctx) error {
+	uc.levels.Reset(c.Query("logger"))
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	recordAudit(c, uc.audit, principal.ID, entities.AuditLogLevelChanged, map[string]interface{}{"logger": c.Query("logger"), "reset": true})
+	return handlers.Response(c, entities.Response{Status: "OK", Data: uc.levels.List(), StatusCode: 200}, map[string]interface{}{"function": "ResetLogLevel"})
+}
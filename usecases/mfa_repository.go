@@ -0,0 +1,17 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+type mfaRepository interface {
+	auditRepository
+	SetPendingMFASecret(userId string, secret string, ctx context.Context) error
+	EnableMFA(userId string, secret string, recoveryCodes []string, step int64, ctx context.Context) error
+	UseTOTPStep(userId string, step int64, ctx context.Context) error
+	ConsumeRecoveryCode(userId string, codeHash string, ctx context.Context) error
+	CreateMFAChallenge(challenge entities.MFAChallenge, ctx context.Context) error
+	UseMFAChallengeAttempt(challengeId string, userId string, maxAttempts int, ctx context.Context) error
+	ConsumeMFAChallenge(challengeId string, ctx context.Context) error
+}
@@ -0,0 +1,226 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/metrics"
+	"backend-challenge/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/skip2/go-qrcode"
+)
+
+const recoveryCodeCount = 10
+
+// mfaChallengeAttempts is how many codes can be tried against one challenge.
+const mfaChallengeAttempts = 5
+
+type MFAConfig struct {
+	// Issuer is the account label shown in authenticator apps.
+	Issuer string
+	// ChallengeTTL is how long the token returned by Login can be exchanged.
+	ChallengeTTL time.Duration
+}
+
+type mfa struct {
+	repo mfaRepository
+	cfg  MFAConfig
+}
+
+func WithMFA(repo mfaRepository, cfg MFAConfig) Option {
+	return func(uc *HttpUser) {
+		uc.mfa = &mfa{repo: repo, cfg: cfg}
+	}
+}
+
+func (uc *HttpUser) EnrollMFA(c *fiber.Ctx) error {
+	if uc.mfa == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	if err := uc.mfa.repo.SetPendingMFASecret(userId, secret, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	uri := utils.TOTPURI(uc.mfa.cfg.Issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "EnrollMFA"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Scan the QR code and confirm with a code", StatusCode: 200, Data: entities.MFAEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: uri,
+		QRCodePNG:  base64.StdEncoding.EncodeToString(png),
+	}}, map[string]interface{}{"function": "EnrollMFA"})
+}
+
+func (uc *HttpUser) ConfirmMFA(c *fiber.Ctx) error {
+	if uc.mfa == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	var bodyRequest entities.MFAConfirmRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ConfirmMFA"})
+		}
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+	if user.MFA == nil || user.MFA.PendingSecret == "" {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA enrolment was not started", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	step, ok := utils.ValidateTOTP(user.MFA.PendingSecret, bodyRequest.Code, time.Now(), 1)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Invalid code", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	if err := uc.mfa.repo.EnableMFA(userId, user.MFA.PendingSecret, hashes, step, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ConfirmMFA"})
+	}
+
+	recordAudit(c, uc.mfa.repo, userId, entities.AuditMFAEnabled, nil)
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "MFA enabled, store the recovery codes in a safe place", StatusCode: 200, Data: map[string]interface{}{
+		"recoveryCodes": codes,
+	}}, map[string]interface{}{"function": "ConfirmMFA"})
+}
+
+func (uc *HttpUser) VerifyMFA(c *fiber.Ctx) error {
+	if uc.mfa == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "VerifyMFA"})
+	}
+
+	var bodyRequest entities.MFAVerifyRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyMFA"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyMFA"})
+		}
+	}
+
+	userId, challengeId, err := utils.ParseActionToken(bodyRequest.MFAToken, utils.PurposeMFAChallenge)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA challenge is invalid or expired", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA", "reason": err.Error()})
+	}
+
+	if err := uc.mfa.repo.UseMFAChallengeAttempt(challengeId, userId, mfaChallengeAttempts, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA challenge is invalid or expired", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA", "reason": err.Error()})
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil || !user.MFAEnabled() {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA challenge is invalid or expired", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA"})
+	}
+
+	if step, ok := utils.ValidateTOTP(user.MFA.Secret, bodyRequest.Code, time.Now(), 1); ok {
+		if err := uc.mfa.repo.UseTOTPStep(userId, step, c.UserContext()); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA"})
+		}
+	} else {
+		if err := uc.mfa.repo.ConsumeRecoveryCode(userId, hashRecoveryCode(bodyRequest.Code), c.UserContext()); err != nil {
+			metrics.Logins.WithLabelValues("VerifyMFA", "failure").Inc()
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Invalid code", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA"})
+		}
+		recordAudit(c, uc.mfa.repo, userId, entities.AuditMFARecoveryUsed, nil)
+	}
+
+	// a challenge is exchanged once, a concurrent request with another valid code loses here
+	if err := uc.mfa.repo.ConsumeMFAChallenge(challengeId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "MFA challenge is invalid or expired", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "VerifyMFA", "reason": err.Error()})
+	}
+
+	return uc.issueAccessToken(c, userId, "VerifyMFA")
+}
+
+// mfaChallenge answers a login for an MFA user with a short lived
+// challenge token instead of an access token.
+func (uc *HttpUser) mfaChallenge(c *fiber.Ctx, userId string, function string) error {
+	token, challengeId, err := utils.GenerateActionToken(userId, utils.PurposeMFAChallenge, uc.mfa.cfg.ChallengeTTL)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": function})
+	}
+
+	now := time.Now()
+	challenge := entities.MFAChallenge{
+		ID:        challengeId,
+		UserID:    userId,
+		ExpiresAt: now.Add(uc.mfa.cfg.ChallengeTTL),
+		CreatedAt: now,
+	}
+	if err := uc.mfa.repo.CreateMFAChallenge(challenge, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": function})
+	}
+
+	return handlers.Response(c,
+		entities.Response{Status: "OK", Message: "MFA required", StatusCode: 200, Data: map[string]interface{}{
+			"mfaRequired": true,
+			"mfaToken":    token,
+		}}, map[string]interface{}{"function": function})
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return utils.Hash(normalized)
+}
@@ -0,0 +1,29 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+	"time"
+)
+
+type oauthRepository interface {
+	auditRepository
+	CreateOAuthClient(client entities.OAuthClient, ctx context.Context) error
+	ListOAuthClients(ctx context.Context) ([]entities.OAuthClient, error)
+	GetOAuthClient(clientId string, ctx context.Context) (entities.OAuthClient, error)
+	RevokeOAuthClient(clientId string, ctx context.Context) error
+	SaveOAuthConsent(consent entities.OAuthConsent, ctx context.Context) error
+	GetOAuthConsent(userId string, clientId string, ctx context.Context) (entities.OAuthConsent, error)
+	ListOAuthConsents(userId string, ctx context.Context) ([]entities.OAuthConsent, error)
+	RevokeOAuthConsent(userId string, clientId string, ctx context.Context) error
+	CreateOAuthCode(code entities.OAuthCode, ctx context.Context) error
+	ConsumeOAuthCode(codeHash string, ctx context.Context) (entities.OAuthCode, error)
+	CreateOAuthRefreshToken(token entities.OAuthRefreshToken, ctx context.Context) error
+	GetOAuthRefreshToken(tokenHash string, ctx context.Context) (entities.OAuthRefreshToken, error)
+	ConsumeOAuthRefreshToken(tokenHash string, ctx context.Context) (entities.OAuthRefreshToken, error)
+	RevokeOAuthRefreshFamily(family string, ctx context.Context) error
+	RevokeAccessToken(tokenId string, expiresAt time.Time, ctx context.Context) error
+	IsAccessTokenRevoked(tokenId string, ctx context.Context) (bool, error)
+	OAuthTokensRevokedAt(clientId string, userId string, ctx context.Context) (time.Time, error)
+	TokensRevokedAt(userId string, ctx context.Context) (time.Time, error)
+}
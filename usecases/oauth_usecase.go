@@ -0,0 +1,542 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/utils"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OAuthConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	CodeTTL         time.Duration
+}
+
+// HttpOAuth lets first-party apps use this service as their OAuth2
+// authorization server.
+type HttpOAuth struct {
+	repo     oauthRepository
+	validate *validator.Validate
+	config   OAuthConfig
+}
+
+func NewHttpOAuth(validate *validator.Validate, repo oauthRepository, config OAuthConfig) HttpOAuth {
+	return HttpOAuth{validate: validate, repo: repo, config: config}
+}
+
+// oauthError writes an RFC 6749 error response. The token, introspection and
+// revocation endpoints answer in the OAuth wire format instead of
+// entities.Response, since they are called by OAuth client libraries.
+func oauthError(c *fiber.Ctx, statusCode int, code string, description string, function string) error {
+	logging.FromContext(c.UserContext()).Errorw("response error", "status_code", statusCode, "error", code, "error_message", description, "function", function)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(statusCode).JSON(fiber.Map{"error": code, "error_description": description})
+}
+
+func oauthResponse(c *fiber.Ctx, body fiber.Map, function string) error {
+	logging.FromContext(c.UserContext()).Infow("response success", "status_code", 200, "function", function)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+	return c.Status(200).JSON(body)
+}
+
+var errInvalidClient = errors.New("client authentication failed")
+
+// authenticateClient reads client credentials from HTTP Basic auth or the
+// form body. Public clients authenticate with their client_id only.
+func (uc *HttpOAuth) authenticateClient(c *fiber.Ctx) (entities.OAuthClient, error) {
+	clientId, secret := c.FormValue("client_id"), c.FormValue("client_secret")
+	if auth := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Basic ") {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return entities.OAuthClient{}, errInvalidClient
+		}
+		id, pass, ok := strings.Cut(string(raw), ":")
+		if !ok {
+			return entities.OAuthClient{}, errInvalidClient
+		}
+		clientId, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(pass)
+	}
+	if clientId == "" {
+		return entities.OAuthClient{}, errInvalidClient
+	}
+
+	client, err := uc.repo.GetOAuthClient(clientId, c.UserContext())
+	if err != nil || !client.Active() {
+		return entities.OAuthClient{}, errInvalidClient
+	}
+	if client.Public {
+		if secret != "" {
+			return entities.OAuthClient{}, errInvalidClient
+		}
+		return client, nil
+	}
+	if secret == "" || !utils.HashEqual(client.SecretHash, utils.Hash(secret)) {
+		return entities.OAuthClient{}, errInvalidClient
+	}
+
+	return client, nil
+}
+
+// oauthScopes are the scopes a client can be granted.
+var oauthScopes = []string{entities.ScopeUsersRead, entities.ScopeUsersWrite}
+
+// resolveScopes parses a space separated scope parameter. An empty request
+// gets every allowed scope; anything outside allowed is rejected. Scopes a
+// client cannot hold, e.g. admin on a client registered before it was
+// refused, are never granted.
+func resolveScopes(requested string, allowed []string) ([]string, bool) {
+	grantable := []string{}
+	for _, scope := range allowed {
+		if containsAll(oauthScopes, []string{scope}) {
+			grantable = append(grantable, scope)
+		}
+	}
+	allowed = grantable
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, true
+	}
+	return scopes, containsAll(allowed, scopes)
+}
+
+func containsAll(set []string, items []string) bool {
+	for _, item := range items {
+		found := false
+		for _, s := range set {
+			if s == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (uc *HttpOAuth) CreateClient(c *fiber.Ctx) error {
+	var bodyRequest entities.CreateOAuthClientRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateOAuthClient"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateOAuthClient"})
+		}
+	}
+
+	client := entities.OAuthClient{
+		ID:           primitive.NewObjectID(),
+		Name:         bodyRequest.Name,
+		RedirectURIs: bodyRequest.RedirectURIs,
+		GrantTypes:   bodyRequest.GrantTypes,
+		Scopes:       bodyRequest.Scopes,
+		Public:       bodyRequest.Public,
+		CreatedAt:    time.Now(),
+	}
+	if client.AllowsGrant(entities.GrantAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "redirectUris is required for the authorization_code grant", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateOAuthClient"})
+	}
+	if client.Public && client.AllowsGrant(entities.GrantClientCredentials) {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "public clients cannot use the client_credentials grant", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "CreateOAuthClient"})
+	}
+
+	client.ClientID, err = utils.RandomToken(16)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "CreateOAuthClient"})
+	}
+	var secret string
+	if !client.Public {
+		secret, err = utils.RandomToken(32)
+		if err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "CreateOAuthClient"})
+		}
+		client.SecretHash = utils.Hash(secret)
+	}
+
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	client.CreatedBy = principal.Type + ":" + principal.ID
+	if err := uc.repo.CreateOAuthClient(client, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "CreateOAuthClient"})
+	}
+
+	recordAudit(c, uc.repo, principal.ID, entities.AuditOAuthClientCreated, map[string]interface{}{"clientId": client.ClientID})
+	data := map[string]interface{}{"client": client}
+	message := "OAuth client created"
+	if secret != "" {
+		data["clientSecret"] = secret
+		message = "OAuth client created, the secret is shown only once"
+	}
+	return handlers.Response(c, entities.Response{Status: "OK", Message: message, StatusCode: 200, Data: data}, map[string]interface{}{"function": "CreateOAuthClient"})
+}
+
+func (uc *HttpOAuth) GetClients(c *fiber.Ctx) error {
+	clients, err := uc.repo.ListOAuthClients(c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "GetOAuthClients"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: clients, StatusCode: 200}, map[string]interface{}{"function": "GetOAuthClients"})
+}
+
+func (uc *HttpOAuth) GetClient(c *fiber.Ctx) error {
+	client, err := uc.repo.GetOAuthClient(c.Params("clientId"), c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "GetOAuthClient"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: client, StatusCode: 200}, map[string]interface{}{"function": "GetOAuthClient"})
+}
+
+func (uc *HttpOAuth) DeleteClient(c *fiber.Ctx) error {
+	clientId := c.Params("clientId")
+	if err := uc.repo.RevokeOAuthClient(clientId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "DeleteOAuthClient"})
+	}
+
+	principal, _ := entities.PrincipalFromContext(c.UserContext())
+	recordAudit(c, uc.repo, principal.ID, entities.AuditOAuthClientRevoked, map[string]interface{}{"clientId": clientId})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "OAuth client revoked", StatusCode: 200}, map[string]interface{}{"function": "DeleteOAuthClient"})
+}
+
+// Authorize is called by the logged-in user's browser app. It records consent
+// and returns the client's redirect URI with a single-use authorization code.
+func (uc *HttpOAuth) Authorize(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+
+	var bodyRequest entities.AuthorizeRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OAuthAuthorize"})
+		}
+	}
+
+	// never redirect to an address the client did not register
+	client, err := uc.repo.GetOAuthClient(bodyRequest.ClientID, c.UserContext())
+	if err != nil || !client.Active() || !client.AllowsGrant(entities.GrantAuthorizationCode) || !client.AllowsRedirect(bodyRequest.RedirectURI) {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "unknown client or redirect_uri", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+
+	scopes, ok := resolveScopes(bodyRequest.Scope, client.Scopes)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "requested scope is not allowed for this client", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+
+	consent, err := uc.repo.GetOAuthConsent(userId, client.ClientID, c.UserContext())
+	if err != nil || !containsAll(consent.Scopes, scopes) {
+		if !bodyRequest.Approve {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "consent required", ErrorCode: "ER403", StatusCode: 403, Data: map[string]interface{}{
+				"client": map[string]interface{}{"clientId": client.ClientID, "name": client.Name},
+				"scopes": scopes,
+			}}, map[string]interface{}{"function": "OAuthAuthorize"})
+		}
+
+		now := time.Now()
+		consent = entities.OAuthConsent{UserID: userId, ClientID: client.ClientID, Scopes: scopes, CreatedAt: now, UpdatedAt: now}
+		if err := uc.repo.SaveOAuthConsent(consent, c.UserContext()); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OAuthAuthorize"})
+		}
+		recordAudit(c, uc.repo, userId, entities.AuditOAuthConsentGranted, map[string]interface{}{"clientId": client.ClientID, "scopes": scopes})
+	}
+
+	code, err := utils.RandomToken(32)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+	now := time.Now()
+	err = uc.repo.CreateOAuthCode(entities.OAuthCode{
+		ID:            utils.Hash(code),
+		ClientID:      client.ClientID,
+		UserID:        userId,
+		RedirectURI:   bodyRequest.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: bodyRequest.CodeChallenge,
+		ExpiresAt:     now.Add(uc.config.CodeTTL),
+		CreatedAt:     now,
+	}, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+
+	redirect, err := url.Parse(bodyRequest.RedirectURI)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OAuthAuthorize"})
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	if bodyRequest.State != "" {
+		query.Set("state", bodyRequest.State)
+	}
+	redirect.RawQuery = query.Encode()
+
+	return handlers.Response(c, entities.Response{Status: "OK", StatusCode: 200, Data: map[string]interface{}{
+		"redirectUri": redirect.String(),
+	}}, map[string]interface{}{"function": "OAuthAuthorize"})
+}
+
+// Token is the RFC 6749 token endpoint.
+func (uc *HttpOAuth) Token(c *fiber.Ctx) error {
+	client, err := uc.authenticateClient(c)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		return oauthError(c, 401, "invalid_client", err.Error(), "OAuthToken")
+	}
+
+	grantType := c.FormValue("grant_type")
+	if !client.AllowsGrant(grantType) {
+		return oauthError(c, 400, "unauthorized_client", "grant type is not allowed for this client", "OAuthToken")
+	}
+
+	switch grantType {
+	case entities.GrantAuthorizationCode:
+		return uc.exchangeCode(c, client)
+	case entities.GrantClientCredentials:
+		return uc.clientCredentials(c, client)
+	case entities.GrantRefreshToken:
+		return uc.refreshToken(c, client)
+	}
+	return oauthError(c, 400, "unsupported_grant_type", "unsupported grant type", "OAuthToken")
+}
+
+func (uc *HttpOAuth) exchangeCode(c *fiber.Ctx, client entities.OAuthClient) error {
+	code, err := uc.repo.ConsumeOAuthCode(utils.Hash(c.FormValue("code")), c.UserContext())
+	if err != nil {
+		return oauthError(c, 400, "invalid_grant", err.Error(), "OAuthToken")
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != c.FormValue("redirect_uri") {
+		return oauthError(c, 400, "invalid_grant", "authorization code was issued to another client or redirect_uri", "OAuthToken")
+	}
+	if !utils.VerifyPKCE(c.FormValue("code_verifier"), code.CodeChallenge) {
+		return oauthError(c, 400, "invalid_grant", "code_verifier does not match", "OAuthToken")
+	}
+
+	return uc.issueTokens(c, client, code.UserID, code.Scopes, uuid.New().String())
+}
+
+func (uc *HttpOAuth) clientCredentials(c *fiber.Ctx, client entities.OAuthClient) error {
+	if client.Public {
+		return oauthError(c, 400, "unauthorized_client", "public clients cannot use client_credentials", "OAuthToken")
+	}
+	scopes, ok := resolveScopes(c.FormValue("scope"), client.Scopes)
+	if !ok {
+		return oauthError(c, 400, "invalid_scope", "requested scope is not allowed for this client", "OAuthToken")
+	}
+
+	return uc.issueTokens(c, client, "", scopes, "")
+}
+
+func (uc *HttpOAuth) refreshToken(c *fiber.Ctx, client entities.OAuthClient) error {
+	ctx := c.UserContext()
+	token, err := uc.repo.ConsumeOAuthRefreshToken(utils.Hash(c.FormValue("refresh_token")), ctx)
+	if err != nil || token.ClientID != client.ClientID {
+		return oauthError(c, 400, "invalid_grant", "refresh token is invalid", "OAuthToken")
+	}
+
+	// a rotated token presented again means it leaked, so the whole family goes
+	if token.RevokedAt != nil {
+		if err := uc.repo.RevokeOAuthRefreshFamily(token.Family, ctx); err != nil {
+			logging.FromContext(ctx).Errorw("failed to revoke refresh token family", "family", token.Family, "error", err)
+		}
+		recordAudit(c, uc.repo, token.UserID, entities.AuditOAuthRefreshReuse, map[string]interface{}{"clientId": client.ClientID})
+		return oauthError(c, 400, "invalid_grant", "refresh token is invalid", "OAuthToken")
+	}
+	if !token.Active(time.Now()) {
+		return oauthError(c, 400, "invalid_grant", "refresh token has expired", "OAuthToken")
+	}
+
+	// a password change revokes everything issued before it
+	revokedAt, err := uc.repo.TokensRevokedAt(token.UserID, ctx)
+	if err != nil || (!revokedAt.IsZero() && !token.CreatedAt.After(revokedAt)) {
+		return oauthError(c, 400, "invalid_grant", "refresh token has been revoked", "OAuthToken")
+	}
+
+	scopes, ok := resolveScopes(c.FormValue("scope"), token.Scopes)
+	if !ok {
+		return oauthError(c, 400, "invalid_scope", "requested scope exceeds the original grant", "OAuthToken")
+	}
+
+	return uc.issueTokens(c, client, token.UserID, scopes, token.Family)
+}
+
+// issueTokens mints an access token, plus a refresh token in family when the
+// grant is on behalf of a user and the client may refresh.
+func (uc *HttpOAuth) issueTokens(c *fiber.Ctx, client entities.OAuthClient, userId string, scopes []string, family string) error {
+	scope := strings.Join(scopes, " ")
+	claims := utils.Claims{UserID: userId, ClientID: client.ClientID, Scope: scope}
+	claims.ID = uuid.New().String()
+	accessToken, err := utils.GenerateTokenWithClaims(claims, uc.config.AccessTokenTTL)
+	if err != nil {
+		return oauthError(c, 500, "server_error", err.Error(), "OAuthToken")
+	}
+
+	body := fiber.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(uc.config.AccessTokenTTL.Seconds()),
+		"scope":        scope,
+	}
+
+	if userId != "" && client.AllowsGrant(entities.GrantRefreshToken) {
+		refreshToken, err := utils.RandomToken(32)
+		if err != nil {
+			return oauthError(c, 500, "server_error", err.Error(), "OAuthToken")
+		}
+		now := time.Now()
+		err = uc.repo.CreateOAuthRefreshToken(entities.OAuthRefreshToken{
+			ID:        utils.Hash(refreshToken),
+			Family:    family,
+			ClientID:  client.ClientID,
+			UserID:    userId,
+			Scopes:    scopes,
+			ExpiresAt: now.Add(uc.config.RefreshTokenTTL),
+			CreatedAt: now,
+		}, c.UserContext())
+		if err != nil {
+			return oauthError(c, 500, "server_error", err.Error(), "OAuthToken")
+		}
+		body["refresh_token"] = refreshToken
+	}
+
+	return oauthResponse(c, body, "OAuthToken")
+}
+
+// Introspect is the RFC 7662 introspection endpoint. Only tokens issued
+// through /oauth/token are reported as active.
+func (uc *HttpOAuth) Introspect(c *fiber.Ctx) error {
+	if _, err := uc.authenticateClient(c); err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		return oauthError(c, 401, "invalid_client", err.Error(), "OAuthIntrospect")
+	}
+
+	ctx := c.UserContext()
+	raw := c.FormValue("token")
+	inactive := fiber.Map{"active": false}
+
+	if c.FormValue("token_type_hint") != "refresh_token" {
+		if claims, err := utils.ParseToken(raw); err == nil {
+			if claims.ClientID == "" || claims.IssuedAt == nil {
+				return oauthResponse(c, inactive, "OAuthIntrospect")
+			}
+			if revoked, err := uc.repo.IsAccessTokenRevoked(claims.ID, ctx); err != nil || revoked {
+				return oauthResponse(c, inactive, "OAuthIntrospect")
+			}
+			// issued before the client or the user's consent was revoked
+			revokedAt, err := uc.repo.OAuthTokensRevokedAt(claims.ClientID, claims.UserID, ctx)
+			if err != nil || (!revokedAt.IsZero() && claims.IssuedAt.Unix() <= revokedAt.Unix()) {
+				return oauthResponse(c, inactive, "OAuthIntrospect")
+			}
+
+			return oauthResponse(c, fiber.Map{
+				"active":     true,
+				"scope":      claims.Scope,
+				"client_id":  claims.ClientID,
+				"sub":        claims.Subject,
+				"token_type": "Bearer",
+				"jti":        claims.ID,
+				"exp":        claims.ExpiresAt.Unix(),
+				"iat":        claims.IssuedAt.Unix(),
+			}, "OAuthIntrospect")
+		}
+	}
+
+	token, err := uc.repo.GetOAuthRefreshToken(utils.Hash(raw), ctx)
+	if err != nil || !token.Active(time.Now()) {
+		return oauthResponse(c, inactive, "OAuthIntrospect")
+	}
+	return oauthResponse(c, fiber.Map{
+		"active":     true,
+		"scope":      strings.Join(token.Scopes, " "),
+		"client_id":  token.ClientID,
+		"sub":        token.UserID,
+		"token_type": "refresh_token",
+		"exp":        token.ExpiresAt.Unix(),
+		"iat":        token.CreatedAt.Unix(),
+	}, "OAuthIntrospect")
+}
+
+// Revoke is the RFC 7009 revocation endpoint. A client may only revoke its
+// own tokens; unknown tokens are answered with 200 as the RFC requires.
+func (uc *HttpOAuth) Revoke(c *fiber.Ctx) error {
+	client, err := uc.authenticateClient(c)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		return oauthError(c, 401, "invalid_client", err.Error(), "OAuthRevoke")
+	}
+
+	ctx := c.UserContext()
+	raw := c.FormValue("token")
+
+	if token, err := uc.repo.GetOAuthRefreshToken(utils.Hash(raw), ctx); err == nil {
+		if token.ClientID == client.ClientID {
+			if err := uc.repo.RevokeOAuthRefreshFamily(token.Family, ctx); err != nil {
+				return oauthError(c, 503, "temporarily_unavailable", err.Error(), "OAuthRevoke")
+			}
+		}
+		return oauthResponse(c, fiber.Map{}, "OAuthRevoke")
+	}
+
+	if claims, err := utils.ParseToken(raw); err == nil {
+		if claims.ClientID == client.ClientID && claims.ID != "" {
+			if err := uc.repo.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time, ctx); err != nil {
+				return oauthError(c, 503, "temporarily_unavailable", err.Error(), "OAuthRevoke")
+			}
+		}
+	}
+
+	return oauthResponse(c, fiber.Map{}, "OAuthRevoke")
+}
+
+func (uc *HttpOAuth) ListMyConsents(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "ListMyConsents"})
+	}
+
+	consents, err := uc.repo.ListOAuthConsents(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "ListMyConsents"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: consents, StatusCode: 200}, map[string]interface{}{"function": "ListMyConsents"})
+}
+
+func (uc *HttpOAuth) RevokeMyConsent(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "RevokeMyConsent"})
+	}
+
+	clientId := c.Params("clientId")
+	if err := uc.repo.RevokeOAuthConsent(userId, clientId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "RevokeMyConsent"})
+	}
+
+	recordAudit(c, uc.repo, userId, entities.AuditOAuthConsentRevoked, map[string]interface{}{"clientId": clientId})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Consent revoked", StatusCode: 200}, map[string]interface{}{"function": "RevokeMyConsent"})
+}
@@ -0,0 +1,22 @@
+package usecases
+
+import (
+	oidc "backend-challenge/adapters/oidc"
+	"backend-challenge/entities"
+	"context"
+)
+
+type oidcRepository interface {
+	auditRepository
+	CreateOIDCLogin(login entities.OIDCLogin, ctx context.Context) error
+	ConsumeOIDCLogin(state string, ctx context.Context) (entities.OIDCLogin, error)
+	FindUserByIdentity(provider string, subject string, ctx context.Context) (entities.User, error)
+	GetUserByEmail(email string, ctx context.Context) (entities.User, error)
+	LinkIdentity(userId string, identity entities.ExternalIdentity, ctx context.Context) error
+}
+
+type oidcProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (oidc.TokenResponse, error)
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (oidc.IDTokenClaims, error)
+}
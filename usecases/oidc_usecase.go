@@ -0,0 +1,201 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/metrics"
+	"backend-challenge/utils"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OIDCConfig struct {
+	// ProviderName is stored with linked identities, e.g. "oidc" or "azure".
+	ProviderName string
+	// StateTTL is how long a login may take between redirect and callback.
+	StateTTL time.Duration
+	// SecureCookie marks the state cookie Secure, turn it off only for plain
+	// HTTP development setups.
+	SecureCookie bool
+}
+
+// oidcStateCookie binds a login to the browser that started it, so a callback
+// URL from someone else's login cannot be replayed in another browser.
+const oidcStateCookie = "oidc_state"
+
+type oidcLogin struct {
+	provider oidcProvider
+	repo     oidcRepository
+	cfg      OIDCConfig
+}
+
+var errIdentityNotLinkable = errors.New("an account with this email exists, but the email is not verified")
+
+func WithOIDC(provider oidcProvider, repo oidcRepository, cfg OIDCConfig) Option {
+	return func(uc *HttpUser) {
+		uc.oidc = &oidcLogin{provider: provider, repo: repo, cfg: cfg}
+	}
+}
+
+// OIDCLogin starts an authorization code + PKCE login and redirects to the provider.
+func (uc *HttpUser) OIDCLogin(c *fiber.Ctx) error {
+	if uc.oidc == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "SSO is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "OIDCLogin"})
+	}
+
+	state, err := utils.RandomToken(24)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OIDCLogin"})
+	}
+	nonce, err := utils.RandomToken(24)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OIDCLogin"})
+	}
+	verifier, err := utils.GeneratePKCEVerifier()
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OIDCLogin"})
+	}
+
+	now := time.Now()
+	login := entities.OIDCLogin{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(uc.oidc.cfg.StateTTL),
+		CreatedAt:    now,
+	}
+	if err := uc.oidc.repo.CreateOIDCLogin(login, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "OIDCLogin"})
+	}
+
+	target, err := uc.oidc.provider.AuthCodeURL(c.UserContext(), state, nonce, utils.PKCEChallenge(verifier))
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Identity provider is unavailable", ErrorCode: "ER502", StatusCode: 502}, map[string]interface{}{"function": "OIDCLogin", "reason": err.Error()})
+	}
+
+	// Lax, the callback is a top-level redirect from the provider
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		Expires:  login.ExpiresAt,
+		Secure:   uc.oidc.cfg.SecureCookie,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return c.Redirect(target, fiber.StatusFound)
+}
+
+// OIDCCallback finishes the login and answers like /auth/login.
+func (uc *HttpUser) OIDCCallback(c *fiber.Ctx) error {
+	if uc.oidc == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "SSO is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "OIDCCallback"})
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Identity provider error: " + errorCode, ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OIDCCallback"})
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Missing state or code", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OIDCCallback"})
+	}
+
+	if cookie := c.Cookies(oidcStateCookie); subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Login state is invalid or expired", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OIDCCallback", "reason": "state does not match the browser's state cookie"})
+	}
+	c.ClearCookie(oidcStateCookie)
+
+	login, err := uc.oidc.repo.ConsumeOIDCLogin(state, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Login state is invalid or expired", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OIDCCallback"})
+	}
+
+	token, err := uc.oidc.provider.Exchange(c.UserContext(), code, login.CodeVerifier)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Could not sign in with the identity provider", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "OIDCCallback", "reason": err.Error()})
+	}
+
+	claims, err := uc.oidc.provider.VerifyIDToken(c.UserContext(), token.IDToken, login.Nonce)
+	if err != nil {
+		metrics.Logins.WithLabelValues("OIDCCallback", "failure").Inc()
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Could not sign in with the identity provider", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "OIDCCallback", "reason": err.Error()})
+	}
+
+	userId, err := uc.resolveOIDCUser(c, claims.Subject, claims.Email, bool(claims.EmailVerified), claims.Name)
+	if err != nil {
+		if errors.Is(err, errIdentityNotLinkable) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: errIdentityNotLinkable.Error(), ErrorCode: "ER403", StatusCode: 403}, map[string]interface{}{"function": "OIDCCallback"})
+		}
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Could not sign in with the identity provider", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "OIDCCallback", "reason": err.Error()})
+	}
+
+	return uc.completeLogin(c, userId, "OIDCCallback")
+}
+
+// resolveOIDCUser finds the user linked to the external identity, links an
+// existing account by verified email, or provisions a new one. The email has
+// to be verified on both sides: a local account nobody confirmed may have
+// been registered by someone else, who would keep its password.
+func (uc *HttpUser) resolveOIDCUser(c *fiber.Ctx, subject, email string, emailVerified bool, name string) (string, error) {
+	ctx := c.UserContext()
+	provider := uc.oidc.cfg.ProviderName
+	identity := entities.ExternalIdentity{Provider: provider, Subject: subject, LinkedAt: time.Now()}
+
+	if user, err := uc.oidc.repo.FindUserByIdentity(provider, subject, ctx); err == nil {
+		return user.ID.Hex(), nil
+	}
+
+	if email == "" {
+		return "", errors.New("the identity provider did not return an email")
+	}
+
+	if user, err := uc.oidc.repo.GetUserByEmail(email, ctx); err == nil {
+		if !emailVerified || !user.EmailVerified {
+			return "", errIdentityNotLinkable
+		}
+		if err := uc.oidc.repo.LinkIdentity(user.ID.Hex(), identity, ctx); err != nil {
+			return "", err
+		}
+		recordAudit(c, uc.oidc.repo, user.ID.Hex(), entities.AuditIdentityLinked, map[string]interface{}{"provider": provider})
+		return user.ID.Hex(), nil
+	}
+
+	return uc.provisionOIDCUser(c, identity, email, emailVerified, name)
+}
+
+func (uc *HttpUser) provisionOIDCUser(c *fiber.Ctx, identity entities.ExternalIdentity, email string, emailVerified bool, name string) (string, error) {
+	ctx := c.UserContext()
+	if name == "" {
+		name = email
+	}
+
+	// no local password: Login hashes the input, so an empty hash never matches
+	user := entities.User{
+		ID:            primitive.NewObjectID(),
+		Name:          name,
+		Email:         email,
+		CreatedAt:     time.Now().Add(7 * time.Hour),
+		Role:          entities.RoleUser,
+		EmailVerified: emailVerified,
+		Identities:    []entities.ExternalIdentity{identity},
+	}
+	if emailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := uc.repo.CheckDuplicateUser(email, ctx); err != nil {
+		return "", err
+	}
+	if err := uc.repo.Register(user, ctx); err != nil {
+		return "", err
+	}
+
+	recordAudit(c, uc.oidc.repo, user.ID.Hex(), entities.AuditUserProvisioned, map[string]interface{}{"provider": identity.Provider})
+	return user.ID.Hex(), nil
+}
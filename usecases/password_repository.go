@@ -0,0 +1,19 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+type passwordRepository interface {
+	auditRepository
+	GetUserByEmail(email string, ctx context.Context) (entities.User, error)
+	CreatePasswordReset(reset entities.PasswordReset, ctx context.Context) error
+	ConsumePasswordReset(tokenHash string, ctx context.Context) (entities.PasswordReset, error)
+	InvalidatePasswordResets(userId string, ctx context.Context) error
+	UpdatePassword(userId string, passwordHash string, ctx context.Context) error
+}
+
+type auditRepository interface {
+	RecordAudit(event entities.AuditEvent, ctx context.Context) error
+}
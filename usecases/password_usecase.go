@@ -0,0 +1,174 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/utils"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasswordResetConfig struct {
+	// TTL is how long a reset token stays valid.
+	TTL time.Duration
+	// LinkURL is the page that receives the token as the "token" query parameter.
+	LinkURL string
+}
+
+type passwordReset struct {
+	repo   passwordRepository
+	mailer mailer.Mailer
+	cfg    PasswordResetConfig
+}
+
+func WithPasswordReset(repo passwordRepository, mail mailer.Mailer, cfg PasswordResetConfig) Option {
+	return func(uc *HttpUser) {
+		uc.password = &passwordReset{repo: repo, mailer: mail, cfg: cfg}
+	}
+}
+
+func (uc *HttpUser) ForgotPassword(c *fiber.Ctx) error {
+	if uc.password == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Password reset is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "ForgotPassword"})
+	}
+
+	var bodyRequest entities.ForgotPasswordRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ForgotPassword"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ForgotPassword"})
+		}
+	}
+
+	// always answer the same way so the endpoint cannot be used to probe for accounts
+	response := entities.Response{Status: "OK", Message: "If the account exists, a reset email has been sent", StatusCode: 200}
+	logger := logging.FromContext(c.UserContext())
+
+	user, err := uc.password.repo.GetUserByEmail(bodyRequest.Email, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, response, map[string]interface{}{"function": "ForgotPassword"})
+	}
+
+	if err := uc.password.repo.InvalidatePasswordResets(user.ID.Hex(), c.UserContext()); err != nil {
+		logger.Errorw("failed to invalidate reset tokens", "error", err)
+	}
+	if err := uc.sendPasswordReset(c.UserContext(), user); err != nil {
+		logger.Errorw("failed to send reset email", "error", err)
+	}
+
+	return handlers.Response(c, response, map[string]interface{}{"function": "ForgotPassword"})
+}
+
+func (uc *HttpUser) ResetPassword(c *fiber.Ctx) error {
+	if uc.password == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Password reset is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "ResetPassword"})
+	}
+
+	var bodyRequest entities.ResetPasswordRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResetPassword"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResetPassword"})
+		}
+	}
+
+	reset, err := uc.password.repo.ConsumePasswordReset(utils.Hash(bodyRequest.Token), c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Reset token is invalid, expired or already used", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResetPassword"})
+	}
+
+	if err := uc.password.repo.UpdatePassword(reset.UserID, utils.Hash(bodyRequest.NewPassword), c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResetPassword"})
+	}
+
+	recordAudit(c, uc.password.repo, reset.UserID, entities.AuditPasswordReset, nil)
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Password has been reset", StatusCode: 200}, map[string]interface{}{"function": "ResetPassword"})
+}
+
+func (uc *HttpUser) ChangePassword(c *fiber.Ctx) error {
+	if uc.password == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Password change is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	var bodyRequest entities.ChangePasswordRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ChangePassword"})
+		}
+	}
+
+	user, err := uc.repo.GetUser(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	if !utils.HashEqual(user.Password, utils.Hash(bodyRequest.CurrentPassword)) {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Current password is incorrect", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	if err := uc.password.repo.UpdatePassword(userId, utils.Hash(bodyRequest.NewPassword), c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ChangePassword"})
+	}
+
+	recordAudit(c, uc.password.repo, userId, entities.AuditPasswordChanged, nil)
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Password changed, please log in again", StatusCode: 200}, map[string]interface{}{"function": "ChangePassword"})
+}
+
+func (uc *HttpUser) sendPasswordReset(ctx context.Context, user entities.User) error {
+	cfg := uc.password.cfg
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return fmt.Errorf("generate reset token: %w", err)
+	}
+
+	now := time.Now()
+	reset := entities.PasswordReset{
+		ID:        utils.Hash(token),
+		UserID:    user.ID.Hex(),
+		ExpiresAt: now.Add(cfg.TTL),
+		CreatedAt: now,
+	}
+	if err := uc.password.repo.CreatePasswordReset(reset, ctx); err != nil {
+		return fmt.Errorf("store reset token: %w", err)
+	}
+
+	msg, err := mailer.Render("reset_password", user.Email, map[string]interface{}{
+		"Name":      user.Name,
+		"Token":     token,
+		"Link":      cfg.LinkURL + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": reset.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.password.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,14 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+type sessionRepository interface {
+	auditRepository
+	CreateSession(session entities.Session, ctx context.Context) error
+	ListSessions(userId string, ctx context.Context) ([]entities.Session, error)
+	RevokeSession(userId string, sessionId string, ctx context.Context) error
+	RevokeUserSessions(userId string, ctx context.Context) error
+}
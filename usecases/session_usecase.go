@@ -0,0 +1,116 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type sessions struct {
+	repo sessionRepository
+}
+
+func WithSessions(repo sessionRepository) Option {
+	return func(uc *HttpUser) {
+		uc.sessions = &sessions{repo: repo}
+	}
+}
+
+// createSession records the login and returns the claims that tie the access
+// token to it.
+func (uc *HttpUser) createSession(c *fiber.Ctx, userId string, expiry time.Duration) (utils.Claims, error) {
+	now := time.Now()
+	session := entities.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userId,
+		TokenID:    uuid.New().String(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		IP:         c.IP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(expiry),
+	}
+	if err := uc.sessions.repo.CreateSession(session, c.UserContext()); err != nil {
+		return utils.Claims{}, err
+	}
+
+	claims := utils.Claims{SessionID: session.ID.Hex()}
+	claims.ID = session.TokenID
+	return claims, nil
+}
+
+func (uc *HttpUser) ListMySessions(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "ListMySessions"})
+	}
+	return uc.listSessions(c, userId, "ListMySessions")
+}
+
+func (uc *HttpUser) RevokeMySession(c *fiber.Ctx) error {
+	userId, ok := currentUserID(c)
+	if !ok {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Unauthorized", ErrorCode: "ER401", StatusCode: 401}, map[string]interface{}{"function": "RevokeMySession"})
+	}
+	return uc.revokeSession(c, userId, c.Params("id"), "RevokeMySession")
+}
+
+func (uc *HttpUser) ListUserSessions(c *fiber.Ctx) error {
+	return uc.listSessions(c, c.Params("id"), "ListUserSessions")
+}
+
+func (uc *HttpUser) RevokeUserSession(c *fiber.Ctx) error {
+	return uc.revokeSession(c, c.Params("id"), c.Params("sessionId"), "RevokeUserSession")
+}
+
+func (uc *HttpUser) RevokeUserSessions(c *fiber.Ctx) error {
+	if uc.sessions == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Sessions are disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "RevokeUserSessions"})
+	}
+
+	userId := c.Params("id")
+	if err := uc.sessions.repo.RevokeUserSessions(userId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": "RevokeUserSessions"})
+	}
+
+	actor, _ := currentUserID(c)
+	recordAudit(c, uc.sessions.repo, userId, entities.AuditSessionRevoked, map[string]interface{}{"session": "all", "revokedBy": actor})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Sessions revoked", StatusCode: 200}, map[string]interface{}{"function": "RevokeUserSessions"})
+}
+
+func (uc *HttpUser) listSessions(c *fiber.Ctx, userId string, function string) error {
+	if uc.sessions == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Sessions are disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": function})
+	}
+
+	result, err := uc.sessions.repo.ListSessions(userId, c.UserContext())
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": function})
+	}
+
+	current, _ := c.UserContext().Value(entities.SessionIDKey).(string)
+	for i := range result {
+		result[i].Current = result[i].ID.Hex() == current
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: result, StatusCode: 200}, map[string]interface{}{"function": function})
+}
+
+func (uc *HttpUser) revokeSession(c *fiber.Ctx, userId string, sessionId string, function string) error {
+	if uc.sessions == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Sessions are disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": function})
+	}
+
+	if err := uc.sessions.repo.RevokeSession(userId, sessionId, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Session not found", ErrorCode: "ER404", StatusCode: 404}, map[string]interface{}{"function": function})
+	}
+
+	actor, _ := currentUserID(c)
+	recordAudit(c, uc.sessions.repo, userId, entities.AuditSessionRevoked, map[string]interface{}{"session": sessionId, "revokedBy": actor})
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Session revoked", StatusCode: 200}, map[string]interface{}{"function": function})
+}
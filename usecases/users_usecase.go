@@ -3,20 +3,37 @@ package usecases
 import (
 	handlers "backend-challenge/adapters/http"
 	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/metrics"
 	"backend-challenge/utils"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type HttpUser struct {
-	repo     userRepository
-	validate *validator.Validate
+	repo         userRepository
+	validate     *validator.Validate
+	verification *emailVerification
+	password     *passwordReset
+	mfa          *mfa
+	sessions     *sessions
+	account      *account
+	oidc         *oidcLogin
+	cookies      *cookieSession
 }
 
-func NewHttpUser(validate *validator.Validate, repo userRepository) HttpUser {
-	return HttpUser{validate: validate, repo: repo}
+// Option enables an optional feature of HttpUser.
+type Option func(*HttpUser)
+
+func NewHttpUser(validate *validator.Validate, repo userRepository, opts ...Option) HttpUser {
+	uc := HttpUser{validate: validate, repo: repo}
+	for _, opt := range opts {
+		opt(&uc)
+	}
+	return uc
 }
 
 func (uc *HttpUser) Login(c *fiber.Ctx) error {
@@ -37,18 +54,69 @@ func (uc *HttpUser) Login(c *fiber.Ctx) error {
 	bodyRequest.Password = utils.Hash(bodyRequest.Password)
 	userId, err := uc.repo.Login(bodyRequest, c.UserContext())
 	if err != nil {
+		metrics.Logins.WithLabelValues("Login", "failure").Inc()
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "Login"})
 	}
 
-	authKey, err := utils.GenerateToken(userId, time.Duration(24*time.Hour))
+	return uc.completeLogin(c, userId, "Login")
+}
+
+// completeLogin applies the login policy shared by every way of signing in:
+// unverified emails are refused when required and MFA users get a challenge
+// instead of an access token.
+func (uc *HttpUser) completeLogin(c *fiber.Ctx, userId string, function string) error {
+	if uc.verification != nil || uc.mfa != nil {
+		user, err := uc.repo.GetUser(userId, c.UserContext())
+		if err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": function})
+		}
+		if uc.verification != nil && uc.verification.cfg.RequireVerified && !user.EmailVerified {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Email address is not verified", ErrorCode: "ER403", StatusCode: 403}, map[string]interface{}{"function": function})
+		}
+		if uc.mfa != nil && user.MFAEnabled() {
+			metrics.Logins.WithLabelValues(function, "mfa_required").Inc()
+			return uc.mfaChallenge(c, userId, function)
+		}
+	}
+
+	return uc.issueAccessToken(c, userId, function)
+}
+
+// issueAccessToken finishes a successful login.
+func (uc *HttpUser) issueAccessToken(c *fiber.Ctx, userId string, function string) error {
+	expiry := time.Duration(24 * time.Hour)
+	var claims utils.Claims
+	if uc.sessions != nil {
+		var err error
+		if claims, err = uc.createSession(c, userId, expiry); err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": function})
+		}
+	}
+
+	claims.UserID = userId
+	authKey, err := utils.GenerateTokenWithClaims(claims, expiry)
 	if err != nil {
-		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "Login"})
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": function})
+	}
+
+	metrics.Logins.WithLabelValues(function, "success").Inc()
+
+	// browser clients keep the token in an HttpOnly cookie and never see it
+	if uc.cookies != nil {
+		csrf, err := uc.setAuthCookies(c, authKey, expiry)
+		if err != nil {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER500", StatusCode: 500}, map[string]interface{}{"function": function})
+		}
+		return handlers.Response(c,
+			entities.Response{Status: "OK", Message: "Success", StatusCode: 200, Data: map[string]interface{}{
+				"csrfToken": csrf,
+			}}, map[string]interface{}{"function": function})
 	}
 
 	return handlers.Response(c,
 		entities.Response{Status: "OK", Message: "Success", StatusCode: 200, Data: map[string]interface{}{
 			"accessToken": authKey,
-		}}, map[string]interface{}{"function": "Login"})
+		}}, map[string]interface{}{"function": function})
 }
 
 func (uc *HttpUser) Create(c *fiber.Ctx) error {
@@ -72,11 +140,24 @@ func (uc *HttpUser) Create(c *fiber.Ctx) error {
 	// hash password
 	bodyRequest.Password = utils.Hash(bodyRequest.Password)
 	bodyRequest.CreatedAt = time.Now().Add(7 * time.Hour)
+	bodyRequest.ID = primitive.NewObjectID()
+	bodyRequest.EmailVerified = false
+	bodyRequest.EmailVerifiedAt = nil
+	bodyRequest.PasswordChangedAt = nil
+	bodyRequest.Role = entities.RoleUser
+	bodyRequest.DeletionScheduledAt = nil
 
 	if err := uc.repo.Register(bodyRequest, c.UserContext()); err != nil {
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "Create"})
 	}
 
+	// the account exists already, so a mail failure is logged instead of failing the request
+	if uc.verification != nil {
+		if err := uc.sendVerification(c.UserContext(), bodyRequest); err != nil {
+			logging.FromContext(c.UserContext()).Errorw("failed to send verification email", "error", err)
+		}
+	}
+
 	return handlers.Response(c, entities.Response{Status: "OK", Message: "Register completed", StatusCode: 200}, map[string]interface{}{"function": "Create"})
 }
 
@@ -87,7 +168,7 @@ func (uc *HttpUser) Get(c *fiber.Ctx) error {
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER404", StatusCode: 200}, map[string]interface{}{"function": "Get"})
 	}
 
-	return handlers.Response(c, entities.Response{Status: "OK", Data: user, StatusCode: 200}, map[string]interface{}{"function": "Get"})
+	return handlers.Response(c, entities.Response{Status: "OK", Data: entities.NewUserResponse(user), StatusCode: 200}, map[string]interface{}{"function": "Get"})
 }
 
 func (uc *HttpUser) GetAll(c *fiber.Ctx) error {
@@ -97,7 +178,12 @@ func (uc *HttpUser) GetAll(c *fiber.Ctx) error {
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "GetAll"})
 	}
 
-	return handlers.Response(c, entities.Response{Status: "OK", Data: users, StatusCode: 200}, map[string]interface{}{"function": "GetAll"})
+	result := make([]entities.UserResponse, 0, len(users))
+	for _, user := range users {
+		result = append(result, entities.NewUserResponse(user))
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Data: result, StatusCode: 200}, map[string]interface{}{"function": "GetAll"})
 }
 
 func (uc *HttpUser) Update(c *fiber.Ctx) error {
@@ -123,6 +209,21 @@ func (uc *HttpUser) Update(c *fiber.Ctx) error {
 		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "Update"})
 	}
 
+	// UpdateUser marks a new address unverified; the owner has to confirm it
+	if bodyRequest.Email != "" && uc.verification != nil {
+		logger := logging.FromContext(c.UserContext())
+		if user, err := uc.repo.GetUser(userId, c.UserContext()); err != nil {
+			logger.Errorw("failed to load user for verification email", "error", err)
+		} else {
+			if err := uc.verification.repo.InvalidateEmailVerifications(userId, c.UserContext()); err != nil {
+				logger.Errorw("failed to invalidate verification tokens", "error", err)
+			}
+			if err := uc.sendVerification(c.UserContext(), user); err != nil {
+				logger.Errorw("failed to send verification email", "error", err)
+			}
+		}
+	}
+
 	return handlers.Response(c, entities.Response{Status: "OK", Message: "Update success", StatusCode: 200}, map[string]interface{}{"function": "Update"})
 }
 
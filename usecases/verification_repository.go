@@ -0,0 +1,14 @@
+package usecases
+
+import (
+	"backend-challenge/entities"
+	"context"
+)
+
+type verificationRepository interface {
+	GetUserByEmail(email string, ctx context.Context) (entities.User, error)
+	CreateEmailVerification(verification entities.EmailVerification, ctx context.Context) error
+	ConsumeEmailVerification(tokenId string, ctx context.Context) (entities.EmailVerification, error)
+	InvalidateEmailVerifications(userId string, ctx context.Context) error
+	MarkEmailVerified(userId string, email string, ctx context.Context) error
+}
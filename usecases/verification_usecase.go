@@ -0,0 +1,141 @@
+package usecases
+
+import (
+	handlers "backend-challenge/adapters/http"
+	"backend-challenge/entities"
+	"backend-challenge/pkg/logging"
+	"backend-challenge/pkg/mailer"
+	"backend-challenge/utils"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type VerificationConfig struct {
+	// TTL is how long a verification token stays valid.
+	TTL time.Duration
+	// RequireVerified blocks login until the email address is verified.
+	RequireVerified bool
+	// LinkURL is the page that receives the token as the "token" query parameter.
+	LinkURL string
+}
+
+type emailVerification struct {
+	repo   verificationRepository
+	mailer mailer.Mailer
+	cfg    VerificationConfig
+}
+
+func WithEmailVerification(repo verificationRepository, mail mailer.Mailer, cfg VerificationConfig) Option {
+	return func(uc *HttpUser) {
+		uc.verification = &emailVerification{repo: repo, mailer: mail, cfg: cfg}
+	}
+}
+
+func (uc *HttpUser) VerifyEmail(c *fiber.Ctx) error {
+	if uc.verification == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Email verification is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "VerifyEmail"})
+	}
+
+	var bodyRequest entities.VerifyEmailRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyEmail"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyEmail"})
+		}
+	}
+
+	userId, tokenId, err := utils.ParseActionToken(bodyRequest.Token, utils.PurposeVerifyEmail)
+	if err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Verification token is invalid, expired or already used", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyEmail", "reason": err.Error()})
+	}
+
+	verification, err := uc.verification.repo.ConsumeEmailVerification(tokenId, c.UserContext())
+	if err != nil || verification.UserID != userId {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Verification token is invalid, expired or already used", ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyEmail"})
+	}
+
+	if err := uc.verification.repo.MarkEmailVerified(userId, verification.Email, c.UserContext()); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "VerifyEmail"})
+	}
+
+	return handlers.Response(c, entities.Response{Status: "OK", Message: "Email verified", StatusCode: 200}, map[string]interface{}{"function": "VerifyEmail"})
+}
+
+func (uc *HttpUser) ResendVerification(c *fiber.Ctx) error {
+	if uc.verification == nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: "Email verification is disabled", ErrorCode: "ER501", StatusCode: 501}, map[string]interface{}{"function": "ResendVerification"})
+	}
+
+	var bodyRequest entities.ResendVerificationRequest
+	if err := c.BodyParser(&bodyRequest); err != nil {
+		return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResendVerification"})
+	}
+
+	// validate request body
+	err := uc.validate.Struct(bodyRequest)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			return handlers.Response(c, entities.Response{Status: "ER", ErrorMessage: err.Error(), ErrorCode: "ER400", StatusCode: 400}, map[string]interface{}{"function": "ResendVerification"})
+		}
+	}
+
+	// always answer the same way so the endpoint cannot be used to probe for accounts
+	response := entities.Response{Status: "OK", Message: "If the account exists and is not verified, a new email has been sent", StatusCode: 200}
+	logger := logging.FromContext(c.UserContext())
+
+	user, err := uc.verification.repo.GetUserByEmail(bodyRequest.Email, c.UserContext())
+	if err != nil || user.EmailVerified {
+		return handlers.Response(c, response, map[string]interface{}{"function": "ResendVerification"})
+	}
+
+	if err := uc.verification.repo.InvalidateEmailVerifications(user.ID.Hex(), c.UserContext()); err != nil {
+		logger.Errorw("failed to invalidate verification tokens", "error", err)
+	}
+	if err := uc.sendVerification(c.UserContext(), user); err != nil {
+		logger.Errorw("failed to send verification email", "error", err)
+	}
+
+	return handlers.Response(c, response, map[string]interface{}{"function": "ResendVerification"})
+}
+
+func (uc *HttpUser) sendVerification(ctx context.Context, user entities.User) error {
+	cfg := uc.verification.cfg
+	token, tokenId, err := utils.GenerateActionToken(user.ID.Hex(), utils.PurposeVerifyEmail, cfg.TTL)
+	if err != nil {
+		return fmt.Errorf("generate verification token: %w", err)
+	}
+
+	now := time.Now()
+	verification := entities.EmailVerification{
+		ID:        tokenId,
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		ExpiresAt: now.Add(cfg.TTL),
+		CreatedAt: now,
+	}
+	if err := uc.verification.repo.CreateEmailVerification(verification, ctx); err != nil {
+		return fmt.Errorf("store verification token: %w", err)
+	}
+
+	msg, err := mailer.Render("verify_email", user.Email, map[string]interface{}{
+		"Name":      user.Name,
+		"Token":     token,
+		"Link":      cfg.LinkURL + "?token=" + url.QueryEscape(token),
+		"ExpiresAt": verification.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.verification.mailer.Send(ctx, msg)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// API key มีรูปแบบ bck_<prefix>_<secret>
+// prefix ใช้ค้นหา key ใน DB ส่วน key เต็มเก็บเป็น hash เท่านั้น
+const apiKeyTag = "bck"
+
+// GenerateAPIKey คืนค่า key เต็ม (แสดงให้ผู้ใช้ครั้งเดียว), prefix และ hash ที่ใช้เก็บ
+func GenerateAPIKey() (string, string, string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix := hex.EncodeToString(b)
+
+	secret, err := RandomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key := apiKeyTag + "_" + prefix + "_" + secret
+	return key, prefix, Hash(key), nil
+}
+
+// ParseAPIKey ดึง prefix ออกจาก key
+func ParseAPIKey(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -2,44 +2,222 @@ package utils
 
 import (
 	"errors"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var secretKey = []byte(os.Getenv("JWT_SECRET")) // set ใน .env
+var JWT = new(jwtConfig)
+
+// jwtConfig ค่าที่ใส่และตรวจใน token แต่ละ environment ควรตั้ง issuer/audience ของตัวเอง
+// เพื่อไม่ให้ token จาก environment อื่นที่ใช้ secret เดียวกันใช้ข้ามกันได้
+type jwtConfig struct {
+	Secret   string        `env:"JWT_SECRET" json:"-"`
+	Issuer   string        `env:"JWT_ISSUER,default=backend-challenge" json:",omitempty"`
+	Audience string        `env:"JWT_AUDIENCE,default=backend-challenge" json:",omitempty"`
+	Leeway   time.Duration `env:"JWT_LEEWAY,default=30s" json:",omitempty"`
+
+	// RotationGrace คือเวลาที่ token ซึ่ง sign ด้วย secret เก่ายังใช้ได้หลังเปลี่ยน secret
+	RotationGrace time.Duration `env:"JWT_ROTATION_GRACE,default=1h" json:",omitempty"`
+}
+
+// jwtKeys กัน JWT.Secret ตอนถูกเปลี่ยนขณะ runtime และเก็บ secret ก่อนหน้าไว้ตรวจ token
+// จนถึง previousUntil
+var jwtKeys struct {
+	sync.RWMutex
+	previous      string
+	previousUntil time.Time
+}
+
+// RotateJWTSecret เปลี่ยน secret ที่ใช้ sign token ทันที โดย token ที่ sign ด้วย secret
+// เดิมยังตรวจผ่านได้อีก grace
+func RotateJWTSecret(secret string, grace time.Duration) {
+	jwtKeys.Lock()
+	defer jwtKeys.Unlock()
+	if secret == JWT.Secret {
+		return
+	}
+	jwtKeys.previous, jwtKeys.previousUntil = JWT.Secret, time.Now().Add(grace)
+	JWT.Secret = secret
+}
+
+// jwtSecrets คืน secret ปัจจุบัน กับ secret ก่อนหน้าถ้ายังอยู่ในช่วง grace
+func jwtSecrets() (string, string) {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+	if jwtKeys.previous != "" && time.Now().Before(jwtKeys.previousUntil) {
+		return JWT.Secret, jwtKeys.previous
+	}
+	return JWT.Secret, ""
+}
+
+// Validate ไม่ยอมให้ start ด้วย secret ว่าง เพราะ token ที่ sign ด้วย key ว่างปลอมได้ทุกคน
+func (c *jwtConfig) Validate() error {
+	if c.Secret == "" {
+		return errors.New("JWT_SECRET must be set")
+	}
+	return nil
+}
+
+const (
+	PurposeVerifyEmail  = "verify_email"
+	PurposeMFAChallenge = "mfa_challenge"
+)
+
+// error ของการตรวจ token แยกตามสาเหตุ ไว้ log เท่านั้น ไม่ส่งกลับไปให้ client
+var (
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrTokenIssuer      = errors.New("token issuer is invalid")
+	ErrTokenAudience    = errors.New("token audience is invalid")
+	ErrTokenClaims      = errors.New("token claims are invalid")
+	ErrTokenPurpose     = errors.New("token purpose is invalid")
+)
+
+// Claims คือ claims ของ token ที่ระบบนี้ออกให้
+type Claims struct {
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
 
 // GenerateToken สร้าง JWT ให้ user โดยใส่ userID ลงไป
 func GenerateToken(userID string, expiry time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(expiry).Unix(),
-		"iat":     time.Now().Unix(),
+	return GenerateTokenWithClaims(Claims{UserID: userID}, expiry)
+}
+
+// GenerateTokenWithClaims สร้าง JWT จาก claims ที่ส่งมา เช่น sid, jti, scope
+// แล้วเติม sub, iss, aud, iat, nbf และ exp ให้
+func GenerateTokenWithClaims(claims Claims, expiry time.Duration) (string, error) {
+	now := time.Now()
+	if claims.Subject == "" {
+		claims.Subject = claims.UserID
+	}
+	if claims.Subject == "" {
+		claims.Subject = claims.ClientID
 	}
+	claims.Issuer = JWT.Issuer
+	if JWT.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{JWT.Audience}
+	}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiry))
 
+	secret, _ := jwtSecrets()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	return token.SignedString([]byte(secret))
 }
 
 // ParseToken ตรวจสอบและดึง claims ออกมาจาก token string
-func ParseToken(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// ต้องใช้ HS256 เท่านั้น
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return secretKey, nil
-	})
+func ParseToken(tokenStr string) (*Claims, error) {
+	claims, err := parseClaims(tokenStr)
+	if err != nil {
+		return nil, err
+	}
 
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+	// token ที่ออกให้สำหรับงานเฉพาะ (เช่น ยืนยันอีเมล) ห้ามใช้แทน access token
+	if claims.Purpose != "" {
+		return nil, ErrTokenPurpose
+	}
+	// access token ต้องมี sub ตรงกับ user_id (หรือ client_id สำหรับ client_credentials)
+	if claims.Subject == "" || (claims.UserID != "" && claims.Subject != claims.UserID) {
+		return nil, ErrTokenClaims
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("cannot read claims")
+	return claims, nil
+}
+
+// GenerateActionToken สร้าง token แบบใช้ครั้งเดียวสำหรับงานเฉพาะ (purpose)
+// คืนค่า token และ tokenID (jti) ไว้บันทึกลง DB เพื่อเช็คว่าถูกใช้ไปแล้วหรือยัง
+func GenerateActionToken(userID, purpose string, expiry time.Duration) (string, string, error) {
+	tokenID := uuid.New().String()
+	claims := Claims{
+		Purpose:          purpose,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: userID, ID: tokenID},
+	}
+
+	signed, err := GenerateTokenWithClaims(claims, expiry)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, tokenID, nil
+}
+
+// ParseActionToken ตรวจสอบ token ที่สร้างจาก GenerateActionToken และคืนค่า userID กับ tokenID
+func ParseActionToken(tokenStr, purpose string) (string, string, error) {
+	claims, err := parseClaims(tokenStr)
+	if err != nil {
+		return "", "", err
+	}
+
+	if claims.Purpose != purpose {
+		return "", "", ErrTokenPurpose
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return "", "", ErrTokenClaims
+	}
+
+	return claims.Subject, claims.ID, nil
+}
+
+func parseClaims(tokenStr string) (*Claims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), // ต้องใช้ HS256 เท่านั้น
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(JWT.Leeway),
+	}
+	if JWT.Issuer != "" {
+		options = append(options, jwt.WithIssuer(JWT.Issuer))
+	}
+	if JWT.Audience != "" {
+		options = append(options, jwt.WithAudience(JWT.Audience))
+	}
+
+	current, previous := jwtSecrets()
+	claims, err := parseWithSecret(tokenStr, current, options)
+	// หลังเปลี่ยน secret token ที่ sign ด้วย secret เก่ายังใช้ได้ในช่วง grace
+	if previous != "" && errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		claims, err = parseWithSecret(tokenStr, previous, options)
+	}
+	if err != nil {
+		return nil, tokenError(err)
 	}
 
 	return claims, nil
 }
+
+func parseWithSecret(tokenStr, secret string, options []jwt.ParserOption) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, options...)
+	return claims, err
+}
+
+// tokenError แปลง error ของ jwt เป็น error ของเราตามสาเหตุ
+func tokenError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenAudience
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignature
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return ErrTokenClaims
+	}
+	return ErrTokenMalformed
+}
@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
 )
 
@@ -11,3 +14,17 @@ func Hash(data string) string {
 	bs := hasher.Sum(nil)
 	return fmt.Sprintf("%x", bs)
 }
+
+// RandomToken returns a URL-safe random string built from n random bytes.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashEqual compares two hashes in constant time.
+func HashEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
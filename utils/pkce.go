@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PKCE (RFC 7636) ใช้ method S256 เท่านั้น
+
+// GeneratePKCEVerifier สร้าง code_verifier ความยาว 43 ตัวอักษร
+func GeneratePKCEVerifier() (string, error) {
+	return RandomToken(32)
+}
+
+// PKCEChallenge คำนวณ code_challenge จาก code_verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE ตรวจว่า code_verifier ตรงกับ code_challenge ที่ได้รับตอนขอ code
+func VerifyPKCE(verifier, challenge string) bool {
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
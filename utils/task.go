@@ -1,34 +1,91 @@
 package utils
 
 import (
+	"backend-challenge/pkg/metrics"
 	"context"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
-func StartUserCountLogger(ctx context.Context, db *mongo.Database, logger *zap.SugaredLogger) {
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
+// database hands out the current database; the tasks ask for it on every
+// tick, so they follow the store when it reconnects.
+type database interface {
+	Database() *mongo.Database
+}
+
+// RunUserCountLogger reports the user count every interval() until ctx is
+// done. interval is asked again after each report, so it can change at runtime.
+func RunUserCountLogger(ctx context.Context, db database, logger *zap.SugaredLogger, interval func() time.Duration) {
+	timer := time.NewTimer(interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopped user count logger")
+			return
+		case <-timer.C:
+			timer.Reset(interval())
+			count, err := db.Database().Collection("user").CountDocuments(ctx, bson.M{})
+			if err != nil {
+				logger.Errorw("Failed to count users", "error", err)
+				continue
+			}
+			metrics.Users.Set(float64(count))
+			logger.Infow("Current user count", "count", count)
+		}
+	}
+}
+
+// RunAccountPurger deletes accounts whose deletion grace period has passed,
+// until ctx is done.
+func RunAccountPurger(ctx context.Context, db database, logger *zap.SugaredLogger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		coll := db.Collection("user")
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopped account purger")
+			return
+		case <-ticker.C:
+			users := db.Database().Collection("user")
+			sessions := db.Database().Collection("session")
+			filter := bson.M{"deletionScheduledAt": bson.M{"$lte": time.Now()}}
+			cursor, err := users.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+			if err != nil {
+				logger.Errorw("Failed to find accounts to purge", "error", err)
+				continue
+			}
+
+			var due []struct {
+				ID primitive.ObjectID `bson:"_id"`
+			}
+			if err := cursor.All(ctx, &due); err != nil {
+				logger.Errorw("Failed to read accounts to purge", "error", err)
+				continue
+			}
 
-		for {
-			select {
-			case <-ctx.Done():
-				logger.Info("Stopped user count logger")
-				return
-			case <-ticker.C:
-				count, err := coll.CountDocuments(ctx, bson.M{})
+			for _, u := range due {
+				// re-check the schedule so a cancellation that raced the lookup wins
+				result, err := users.DeleteOne(ctx, bson.M{"_id": u.ID, "deletionScheduledAt": bson.M{"$lte": time.Now()}})
 				if err != nil {
-					logger.Errorw("Failed to count users", "error", err)
+					logger.Errorw("Failed to purge account", "user_id", u.ID.Hex(), "error", err)
 					continue
 				}
-				logger.Infow("Current user count", "count", count)
+				if result.DeletedCount == 0 {
+					continue
+				}
+				if _, err := sessions.UpdateMany(ctx, bson.M{"userId": u.ID.Hex(), "revokedAt": nil}, bson.M{"$set": bson.M{"revokedAt": time.Now()}}); err != nil {
+					logger.Errorw("Failed to revoke sessions of purged account", "user_id", u.ID.Hex(), "error", err)
+				}
+				logger.Infow("Purged account", "user_id", u.ID.Hex())
 			}
 		}
-	}()
+	}
 }